@@ -1,4 +1,7 @@
-﻿package main
+﻿//go:build ignore
+
+// Versao antiga do chaincode, mantida apenas como referencia.
+package main
 
 import (
 	"encoding/json"
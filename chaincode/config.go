@@ -0,0 +1,136 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Papéis descritos no README: suppliers cunham materiais, wandmakers produzem varinhas
+// e admins cuidam do cadastro de owners
+const (
+	roleSupplier  = "supplier"
+	roleWandmaker = "wandmaker"
+	roleAdmin     = "admin"
+)
+
+// A configuração fica em uma chave composta para não colidir com IDs de owners
+const configIndex = "config"
+
+// Configuração do chaincode, definida no Init
+// Language é o idioma das mensagens no canal ("pt-BR" ou "en"), quando a invocação não pede outro
+type StudioConfig struct {
+	ObjectType string              `json:"docType"`
+	Roles      map[string]RoleRule `json:"roles"`
+	Language   string              `json:"language,omitempty"`
+}
+
+// Regra que concede um papel. O cliente tem o papel se pertence a um dos MSPs listados
+// ou se seu certificado possui o atributo Attribute (emitido pela fabric-ca). Com Value vazio basta o atributo existir
+type RoleRule struct {
+	MSPIDs    []string `json:"mspIds"`
+	Attribute string   `json:"attribute"`
+	Value     string   `json:"value"`
+}
+
+func configKey(stub shim.ChaincodeStubInterface) (string, error) {
+	return stub.CreateCompositeKey(configIndex, []string{})
+}
+
+// Valida os papéis e o idioma da configuração passada ao Init (ver parseGenesis)
+func validateConfig(config *StudioConfig) error {
+	for role, rule := range config.Roles {
+		if role != roleSupplier && role != roleWandmaker && role != roleAdmin {
+			return newError(CodeInvalidArgument, msgConfigUnknownRole, map[string]string{"role": role})
+		}
+		if len(rule.MSPIDs) == 0 && rule.Attribute == "" {
+			return newError(CodeInvalidArgument, msgConfigRoleWithoutRule, map[string]string{"role": role})
+		}
+	}
+	if config.Language != "" && !isSupportedLanguage(config.Language) {
+		return newError(CodeInvalidArgument, msgConfigUnknownLanguage, map[string]string{"language": config.Language})
+	}
+	return nil
+}
+
+// Busca a configuração na ledger. Retorna nil se o Init não recebeu configuração
+func getConfig(stub shim.ChaincodeStubInterface) (*StudioConfig, error) {
+	key, err := configKey(stub)
+	if err != nil {
+		return nil, internalError(msgKeyFailed, "config", err)
+	}
+	configBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, internalError(msgReadFailed, "config", err)
+	}
+	if configBytes == nil {
+		rememberConfigLanguage(stub, nil)
+		return nil, nil
+	}
+	var config StudioConfig
+	err = json.Unmarshal(configBytes, &config)
+	if err != nil {
+		return nil, internalError(msgDeserializeFailed, "config", err)
+	}
+	rememberConfigLanguage(stub, &config)
+	return &config, nil
+}
+
+func putConfig(stub shim.ChaincodeStubInterface, config *StudioConfig) error {
+	key, err := configKey(stub)
+	if err != nil {
+		return internalError(msgKeyFailed, "config", err)
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return internalError(msgSerializeFailed, "config", err)
+	}
+	err = stub.PutState(key, configBytes)
+	if err != nil {
+		return internalError(msgWriteFailed, "config", err)
+	}
+	rememberConfigLanguage(stub, config)
+	return nil
+}
+
+// Verifica se quem submeteu a transação tem o papel pedido.
+// Sem roles na configuração (sem configuração, ou uma só com language) os papéis supplier e wandmaker ficam liberados,
+// como antes dos papéis existirem, mas ninguém é admin. Com roles, um papel sem regra não é concedido a ninguém
+func assertCallerHasRole(stub shim.ChaincodeStubInterface, role string) error {
+	config, err := getConfig(stub)
+	if err != nil {
+		return err
+	}
+	if config == nil || config.Roles == nil {
+		if role == roleAdmin {
+			return newError(CodeUnauthorized, msgAccessNoRule, map[string]string{"role": role})
+		}
+		return nil
+	}
+
+	rule, ok := config.Roles[role]
+	if !ok {
+		return newError(CodeUnauthorized, msgAccessNoRule, map[string]string{"role": role})
+	}
+
+	caller, err := getCaller(stub)
+	if err != nil {
+		return err
+	}
+	for _, mspID := range rule.MSPIDs {
+		if caller.MSPID == mspID {
+			return nil
+		}
+	}
+	if rule.Attribute != "" {
+		value, found, err := cid.GetAttributeValue(stub, rule.Attribute)
+		if err != nil {
+			return internalError(msgIdentityFailed, "attribute "+rule.Attribute, err)
+		}
+		if found && (rule.Value == "" || value == rule.Value) {
+			return nil
+		}
+	}
+	return newError(CodeUnauthorized, msgAccessMissingRole, map[string]string{"role": role, "caller": caller.String(), "mspId": caller.MSPID})
+}
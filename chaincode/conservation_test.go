@@ -0,0 +1,343 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"flag"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Testes de propriedade da conservação de materiais: sequências aleatórias de transações, válidas e inválidas,
+// e depois de cada passo a ledger inteira é conferida contra o que a transação pode ter mudado: transações que falham não mudam nada,
+// o swapMaterials só move unidades entre owners, cada varinha guarda exatamente o que saiu do estoque do seu owner, nenhuma pilha
+// fica negativa e, para cada material, o estoque mais o que está dentro das varinhas é igual ao que foi cunhado.
+// São 2000 sequências de conservationSteps transações, ou 20 com -short.
+// Cada sequência usa a própria seed, que aparece no nome do subteste para reproduzir uma falha (-run 'TestConservation/seed=17').
+// O número de sequências muda com -conservation.sequences (go test -run TestConservation -args -conservation.sequences=100000)
+
+var conservationSequences = flag.Int("conservation.sequences", 2000, "number of random sequences run by TestConservation (20 with -short unless set)")
+
+const conservationSteps = 50
+
+var (
+	conservationOwners = []string{"alice", "bob", "carol"}
+	// Inclui grafias diferentes da mesma pilha, que devem cair na mesma entrada
+	conservationMaterials = []string{"ebano", "rubi", "ébano", "e\u0301bano", "pena de fênix", "pena  de fênix"}
+)
+
+func TestConservation(t *testing.T) {
+	sequences := *conservationSequences
+	if testing.Short() {
+		// Com -short o padrão cai para 20, mas um valor passado na linha de comando vale
+		sequences = 20
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "conservation.sequences" {
+				sequences = *conservationSequences
+			}
+		})
+	}
+	for seed := 1; seed <= sequences; seed++ {
+		seed := seed
+		t.Run("seed="+strconv.Itoa(seed), func(t *testing.T) {
+			newConservationRun(t, int64(seed)).run(conservationSteps)
+		})
+	}
+}
+
+// Estado de uma sequência: o harness, as identidades dos owners e o total cunhado de cada material
+type conservationRun struct {
+	h          *harness
+	rnd        *rand.Rand
+	identities map[string][]byte
+	admin      []byte
+	minted     map[string]int
+	wandIDs    []string
+	ledger     ledgerSnapshot
+}
+
+func newConservationRun(t *testing.T, seed int64) *conservationRun {
+	h := newConfiguredHarness(t)
+	run := &conservationRun{
+		h:          h,
+		rnd:        rand.New(rand.NewSource(seed)),
+		identities: make(map[string][]byte),
+		admin:      newIdentity(t, "Org0MSP", "admin", nil),
+		minted:     make(map[string]int),
+	}
+	// Todos os owners são suppliers e wandmakers
+	for _, ownerID := range conservationOwners {
+		run.identities[ownerID] = newIdentity(t, "Org1MSP", ownerID, map[string]string{"studio.role": "wandmaker"})
+		h.as(run.identities[ownerID]).invoke("initOwner", ownerID).ok()
+	}
+	h.as(run.admin).invoke("registerRecipe", "classica", `[{"descricao":"ebano","quantidade":1},{"descricao":"rubi","quantidade":2}]`).ok()
+	h.invoke("registerRecipe", "fenix", `[{"descricao":"pena de fênix","quantidade":1}]`).ok()
+	h.invoke("registerRecipe", "aposentada", `[{"descricao":"ebano","quantidade":1}]`).ok()
+	h.invoke("retireRecipe", "aposentada").ok()
+	run.ledger = run.snapshot()
+	return run
+}
+
+func (run *conservationRun) run(steps int) {
+	for step := 0; step < steps; step++ {
+		run.step()
+	}
+}
+
+// Executa uma transação aleatória e confere a ledger
+func (run *conservationRun) step() {
+	t := run.h.t
+	t.Helper()
+	before := run.ledger
+	args := run.randomInvocation()
+	r := run.h.as(run.randomCaller(args)).invoke(args...)
+	after := run.snapshot()
+	run.ledger = after
+
+	expected := before.clone()
+	if r.response.Status == 200 {
+		run.apply(args, r.response.Payload, expected, after)
+	} else {
+		// Uma transação que falha não muda nada
+		var studioErr StudioError
+		err := json.Unmarshal([]byte(r.response.Message), &studioErr)
+		if err != nil || studioErr.Code == CodeInternal {
+			t.Fatalf("%q: unexpected failure %d: %s", args, r.response.Status, r.response.Message)
+		}
+	}
+	if !reflect.DeepEqual(expected.stock, after.stock) {
+		t.Fatalf("%q (status %d): expected stock %v, got %v", args, r.response.Status, expected.stock, after.stock)
+	}
+	if !reflect.DeepEqual(expected.wands, after.wands) {
+		t.Fatalf("%q (status %d): expected wands %v, got %v", args, r.response.Status, expected.wands, after.wands)
+	}
+
+	// O que foi cunhado está em estoque ou dentro de alguma varinha
+	held := make(map[string]int)
+	for key, quantity := range after.stock {
+		held[key.descricao] += quantity
+	}
+	for _, wand := range after.wands {
+		for _, material := range wand.materials {
+			held[material.Descricao] += material.Quantidade
+		}
+	}
+	if !reflect.DeepEqual(held, run.minted) {
+		t.Fatalf("%q: minted %v, but the ledger holds %v", args, run.minted, held)
+	}
+}
+
+// Aplica ao snapshot anterior o efeito esperado de uma transação que teve sucesso
+func (run *conservationRun) apply(args []string, payload []byte, expected ledgerSnapshot, after ledgerSnapshot) {
+	t := run.h.t
+	t.Helper()
+	switch args[0] {
+	case "initMaterial":
+		descricao := canonicalDescription(args[1])
+		quantity, _ := strconv.Atoi(args[2])
+		expected.stock[stockKey{args[3], descricao}] += quantity
+		run.minted[descricao] += quantity
+		run.h.lastEvent(eventMaterialMinted)
+	case "swapMaterials":
+		descricao := canonicalDescription(args[2])
+		quantity, _ := strconv.Atoi(args[3])
+		expected.add(stockKey{args[1], descricao}, -quantity)
+		expected.add(stockKey{args[4], descricao}, quantity)
+		run.h.lastEvent(eventMaterialTransferred)
+	case "createWand":
+		var wands []Wand
+		if len(args) == 2 {
+			var wand Wand
+			run.decode(args, payload, &wand)
+			wands = []Wand{wand}
+		} else {
+			run.decode(args, payload, &wands)
+		}
+		// Cada varinha leva exatamente o que saiu do estoque do owner
+		for _, wand := range wands {
+			if wand.Owner != args[1] {
+				t.Fatalf("%q: wand created for %s", args, wand.Owner)
+			}
+			for _, material := range wand.Materiais {
+				expected.add(stockKey{args[1], material.Descricao}, -material.Quantidade)
+			}
+			expected.wands[wand.Id] = after.wands[wand.Id]
+			if _, ok := after.wands[wand.Id]; !ok {
+				t.Fatalf("%q: wand %s was not stored", args, wand.Id)
+			}
+			run.wandIDs = append(run.wandIDs, wand.Id)
+		}
+		if len(args) == 4 {
+			count, _ := strconv.Atoi(args[3])
+			if len(wands) != count {
+				t.Fatalf("%q: expected %d wands, got %d", args, count, len(wands))
+			}
+		}
+		run.h.lastEvent(eventWandCreated)
+	case "transferWand":
+		wand := expected.wands[args[2]]
+		wand.owner = args[3]
+		expected.wands[args[2]] = wand
+		run.h.lastEvent(eventWandTransferred)
+	case "consolidateInventory":
+		// As pilhas já estão na forma canônica, não há o que juntar
+		run.h.lastEvent(eventInventoryConsolidated)
+	default:
+		t.Fatalf("%q: unexpected transaction", args)
+	}
+}
+
+func (run *conservationRun) decode(args []string, payload []byte, value interface{}) {
+	err := json.Unmarshal(payload, value)
+	if err != nil {
+		run.h.t.Fatalf("%q: invalid payload %s: %s", args, payload, err)
+	}
+}
+
+// Transação aleatória. Os argumentos incluem owners, materiais, receitas e varinhas que não existem,
+// quantidades inválidas e transferências para o próprio owner
+func (run *conservationRun) randomInvocation() []string {
+	switch n := run.rnd.Intn(20); {
+	case n < 6:
+		return []string{"initMaterial", run.randomMaterial(), run.randomQuantity(), run.randomOwner()}
+	case n < 12:
+		// Metade das vezes uma pilha que existe, enviada pelo seu owner
+		senderID, descricao := run.randomOwner(), run.randomMaterial()
+		if stacks := run.ledger.stacks(); len(stacks) > 0 && run.rnd.Intn(2) == 0 {
+			stack := stacks[run.rnd.Intn(len(stacks))]
+			senderID, descricao = stack.owner, stack.descricao
+		}
+		return []string{"swapMaterials", senderID, descricao, run.randomQuantity(), run.randomOwner()}
+	case n < 15:
+		return []string{"createWand", run.randomOwner()}
+	case n < 17:
+		recipes := []string{"classica", "fenix", "aposentada", "nope"}
+		return []string{"createWand", run.randomOwner(), recipes[run.rnd.Intn(len(recipes))], strconv.Itoa(run.rnd.Intn(4))}
+	case n < 19:
+		// Quase sempre uma varinha que existe, enviada pelo seu owner atual
+		wandID, senderID := "nope", run.randomOwner()
+		if len(run.wandIDs) > 0 && run.rnd.Intn(5) > 0 {
+			wandID = run.wandIDs[run.rnd.Intn(len(run.wandIDs))]
+			if run.rnd.Intn(5) > 0 {
+				senderID = run.ledger.wands[wandID].owner
+			}
+		}
+		return []string{"transferWand", senderID, wandID, run.randomOwner()}
+	default:
+		return []string{"consolidateInventory", run.randomOwner()}
+	}
+}
+
+func (run *conservationRun) randomOwner() string {
+	if run.rnd.Intn(20) == 0 {
+		return "nobody"
+	}
+	return conservationOwners[run.rnd.Intn(len(conservationOwners))]
+}
+
+func (run *conservationRun) randomMaterial() string {
+	return conservationMaterials[run.rnd.Intn(len(conservationMaterials))]
+}
+
+func (run *conservationRun) randomQuantity() string {
+	return strconv.Itoa(run.rnd.Intn(10) - 2)
+}
+
+// Quem assina: quase sempre o dono do primeiro owner dos argumentos, às vezes outro owner.
+// O consolidateInventory é do admin
+func (run *conservationRun) randomCaller(args []string) []byte {
+	if args[0] == "consolidateInventory" {
+		return run.admin
+	}
+	ownerID := args[len(args)-1]
+	if args[0] != "initMaterial" {
+		ownerID = args[1]
+	}
+	if identity, ok := run.identities[ownerID]; ok && run.rnd.Intn(5) > 0 {
+		return identity
+	}
+	return run.identities[conservationOwners[run.rnd.Intn(len(conservationOwners))]]
+}
+
+// Materiais e varinhas da ledger, lidos direto do estado do MockStub
+type ledgerSnapshot struct {
+	stock map[stockKey]int
+	wands map[string]wandSnapshot
+}
+
+type stockKey struct {
+	owner     string
+	descricao string
+}
+
+type wandSnapshot struct {
+	owner     string
+	materials []Material
+}
+
+func (run *conservationRun) snapshot() ledgerSnapshot {
+	t := run.h.t
+	t.Helper()
+	snapshot := ledgerSnapshot{stock: make(map[stockKey]int), wands: make(map[string]wandSnapshot)}
+	materialPrefix := run.h.compositeKey(materialIndex)
+	wandPrefix := run.h.compositeKey(wandIndex)
+	for key, value := range run.h.stub.State {
+		switch {
+		case strings.HasPrefix(key, materialPrefix):
+			var material Material
+			err := json.Unmarshal(value, &material)
+			if err != nil {
+				t.Fatalf("invalid material %s: %s", value, err)
+			}
+			if material.Quantidade <= 0 {
+				t.Fatalf("stored material with quantity %d: %s", material.Quantidade, value)
+			}
+			snapshot.stock[stockKey{material.Owner, material.Descricao}] = material.Quantidade
+		case strings.HasPrefix(key, wandPrefix):
+			var wand Wand
+			err := json.Unmarshal(value, &wand)
+			if err != nil {
+				t.Fatalf("invalid wand %s: %s", value, err)
+			}
+			snapshot.wands[wand.Id] = wandSnapshot{owner: wand.Owner, materials: wand.Materiais}
+		}
+	}
+	return snapshot
+}
+
+// Pilhas em estoque, em uma ordem fixa para que a seed reproduza a sequência
+func (snapshot ledgerSnapshot) stacks() []stockKey {
+	var stacks []stockKey
+	for key := range snapshot.stock {
+		stacks = append(stacks, key)
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		if stacks[i].owner != stacks[j].owner {
+			return stacks[i].owner < stacks[j].owner
+		}
+		return stacks[i].descricao < stacks[j].descricao
+	})
+	return stacks
+}
+
+func (snapshot ledgerSnapshot) clone() ledgerSnapshot {
+	clone := ledgerSnapshot{stock: make(map[stockKey]int), wands: make(map[string]wandSnapshot)}
+	for key, quantity := range snapshot.stock {
+		clone.stock[key] = quantity
+	}
+	for wandID, wand := range snapshot.wands {
+		clone.wands[wandID] = wand
+	}
+	return clone
+}
+
+// Pilhas que chegam a zero saem da ledger
+func (snapshot ledgerSnapshot) add(key stockKey, quantity int) {
+	snapshot.stock[key] += quantity
+	if snapshot.stock[key] == 0 {
+		delete(snapshot.stock, key)
+	}
+}
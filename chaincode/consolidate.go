@@ -0,0 +1,122 @@
+package chaincode
+
+// Junta as entradas de material que são a mesma pilha (mesma descrição canônica) em uma única entrada,
+// na ordem em que cada pilha aparece pela primeira vez. Entradas com quantidade zero ou negativa,
+// deixadas por versões antigas que aceitavam transferências negativas, não são materiais e são descartadas
+func mergeMaterials(ownerID string, materials []Material) ([]Material, error) {
+	merged := []Material{}
+	index := make(map[string]int)
+	for _, material := range materials {
+		if material.Quantidade <= 0 {
+			continue
+		}
+		descricao := canonicalDescription(material.Descricao)
+		i, ok := index[descricao]
+		if !ok {
+			index[descricao] = len(merged)
+			merged = append(merged, Material{
+				ObjectType: docTypeMaterial,
+				Descricao:  descricao,
+				Quantidade: material.Quantidade,
+				Owner:      ownerID,
+			})
+			continue
+		}
+		total, ok := addQuantities(merged[i].Quantidade, material.Quantidade)
+		if !ok {
+			return nil, quantityOverflowError(ownerID, descricao)
+		}
+		merged[i].Quantidade = total
+	}
+	return merged, nil
+}
+
+// Consolidate inventory repara o estoque de um owner que guarda a mesma pilha de material em mais de uma entrada
+// Possui como entrada o ID do owner. Só admins
+// Owners migrados têm as entradas material~owner~descricao juntadas na chave da descrição canônica;
+// owners legados têm a lista de materiais do próprio documento juntada, sem migrar
+// Retorna os materiais do owner depois da consolidação
+func (c *StudioContract) ConsolidateInventory(ctx StudioContextInterface, ownerID string) ([]Material, error) {
+	err := validateIDReference("owner", ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.AssertRole(roleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := ctx.Repository().findOwner(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	legacy := isLegacyOwner(owner)
+	stored := owner.Materiais
+	if !legacy {
+		stored, err = queryMaterials(stub, ownerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	merged, err := mergeMaterials(ownerID, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	// Uma pilha mudou se não havia uma única entrada com a mesma descrição e a mesma quantidade
+	entries := make(map[string][]int)
+	for _, material := range stored {
+		entries[material.Descricao] = append(entries[material.Descricao], material.Quantidade)
+	}
+	var changed []Material
+	for _, material := range merged {
+		quantities := entries[material.Descricao]
+		if len(quantities) != 1 || quantities[0] != material.Quantidade {
+			changed = append(changed, material)
+		}
+	}
+
+	if legacy {
+		if len(changed) > 0 || len(merged) != len(stored) {
+			owner.Materiais = merged
+			err = putOwner(stub, owner)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		// Grava as pilhas que mudaram e apaga as entradas que foram juntadas em outra
+		for i := range changed {
+			err = putMaterial(stub, &changed[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+		kept := make(map[string]bool)
+		for _, material := range merged {
+			kept[material.Descricao] = true
+		}
+		for i := range stored {
+			if kept[stored[i].Descricao] {
+				continue
+			}
+			err = deleteMaterial(stub, &stored[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = ctx.EmitEvent(eventInventoryConsolidated, StudioEvent{
+		Owners:    []string{ownerID},
+		Materiais: changed,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
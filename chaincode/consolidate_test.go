@@ -0,0 +1,61 @@
+package chaincode
+
+import (
+	"testing"
+)
+
+func TestConsolidateMigratedOwner(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "2", "alice").ok()
+	// Pilha gravada por uma versão que não normalizava as descrições
+	h.putRaw(h.compositeKey(materialIndex, "alice", "pena  de fênix"), `{"docType":"material","descricao":"pena  de fênix","quantidade":1,"owner":"alice"}`)
+	h.putRaw(h.compositeKey(materialIndex, "alice", "pena de fênix"), `{"docType":"material","descricao":"pena de fênix","quantidade":2,"owner":"alice"}`)
+
+	var materials []Material
+	h.as(ids.admin).invoke("consolidateInventory", "alice").decode(&materials)
+	if descriptions(materials) != "ebano=2,pena de fênix=3" {
+		t.Fatalf("unexpected materials %v", materials)
+	}
+	event := h.lastEvent(eventInventoryConsolidated)
+	if descriptions(event.Materiais) != "pena de fênix=3" {
+		t.Fatalf("unexpected event materials %v", event.Materiais)
+	}
+	if h.quantity("alice", "pena de fênix") != 3 {
+		t.Fatalf("stacks were not merged")
+	}
+	if _, ok := h.stub.State[h.compositeKey(materialIndex, "alice", "pena  de fênix")]; ok {
+		t.Fatalf("merged entry was not deleted")
+	}
+
+	// Sem nada a juntar, nada muda
+	h.invoke("consolidateInventory", "alice").decode(&materials)
+	if event := h.lastEvent(eventInventoryConsolidated); len(event.Materiais) != 0 {
+		t.Fatalf("unexpected event materials %v", event.Materiais)
+	}
+}
+
+func TestConsolidateLegacyOwner(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.putRaw("old", `{"docType":"owner","id":"old","materiais":[{"descricao":"ebano","quantidade":2},{"descricao":"ebano","quantidade":3},{"descricao":"rubi","quantidade":0}]}`)
+
+	var materials []Material
+	h.as(ids.admin).invoke("consolidateInventory", "old").decode(&materials)
+	if descriptions(materials) != "ebano=5" {
+		t.Fatalf("unexpected materials %v", materials)
+	}
+	var owner Owner
+	h.invoke("QueryOwner", "old").decode(&owner)
+	if descriptions(owner.Materiais) != "ebano=5" {
+		t.Fatalf("legacy document not rewritten: %+v", owner)
+	}
+	// Continua legado até o migrateOwners
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "old").failsWith(CodeMigrationRequired, msgOwnerLegacy)
+}
+
+func TestConsolidateErrors(t *testing.T) {
+	h, ids := newStudioHarness(t)
+
+	h.as(ids.admin).invoke("consolidateInventory", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	h.invoke("consolidateInventory", "").failsWith(CodeInvalidArgument, msgIDEmpty)
+	h.as(ids.alice).invoke("consolidateInventory", "alice").failsWith(CodeUnauthorized, msgAccessMissingRole)
+}
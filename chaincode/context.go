@@ -0,0 +1,59 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Contexto de transação do StudioContract. Além do stub e da identidade do cliente,
+// dá acesso às verificações, aos documentos e ao estoque usados por quase todas as transações
+type StudioContextInterface interface {
+	contractapi.TransactionContextInterface
+	GetCaller() (*callerIdentity, error)
+	AssertRole(role string) error
+	GetOwnerForCaller(ownerID string) (*Owner, error)
+	Repository() *repository
+	Inventory(ownerID string) *inventory
+	EmitEvent(eventType string, event StudioEvent) error
+}
+
+type StudioContext struct {
+	contractapi.TransactionContext
+}
+
+// Identidade de quem submeteu a transação
+func (ctx *StudioContext) GetCaller() (*callerIdentity, error) {
+	return getCaller(ctx.GetStub())
+}
+
+// Verifica se quem submeteu a transação tem o papel pedido, de acordo com a configuração do Init
+func (ctx *StudioContext) AssertRole(role string) error {
+	return assertCallerHasRole(ctx.GetStub(), role)
+}
+
+// Busca um owner que vai ser alterado e verifica se quem submeteu a transação é o seu dono
+func (ctx *StudioContext) GetOwnerForCaller(ownerID string) (*Owner, error) {
+	owner, err := ctx.Repository().findOwnerForUpdate(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	err = assertCallerIsOwner(ctx.GetStub(), owner)
+	if err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// Owners, varinhas e receitas da ledger
+func (ctx *StudioContext) Repository() *repository {
+	return newRepository(ctx.GetStub())
+}
+
+// Estoque de materiais de um owner nesta transação
+func (ctx *StudioContext) Inventory(ownerID string) *inventory {
+	return newInventory(ctx.GetStub(), ownerID)
+}
+
+// Registra o evento da transação
+func (ctx *StudioContext) EmitEvent(eventType string, event StudioEvent) error {
+	return emitEvent(ctx.GetStub(), eventType, event)
+}
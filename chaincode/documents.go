@@ -0,0 +1,63 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Tipos de documento gravados na ledger, no campo docType
+const (
+	docTypeOwner    = "owner"
+	docTypeMaterial = "material"
+	docTypeWand     = "wand"
+	docTypeRecipe   = "recipe"
+	docTypeConfig   = "config"
+)
+
+// Envelope comum a todos os documentos: lê só o docType, para decidir se e como o documento deve ser deserializado
+type documentEnvelope struct {
+	ObjectType string `json:"docType"`
+}
+
+// Retorna o docType de um valor da ledger. Valores que não são documentos JSON (como o índice wandId) têm docType vazio
+func documentType(value []byte) string {
+	var envelope documentEnvelope
+	err := json.Unmarshal(value, &envelope)
+	if err != nil {
+		return ""
+	}
+	return envelope.ObjectType
+}
+
+// Lista os documentos de owner da ledger. Chaves de qualquer outro tipo são ignoradas
+func queryOwners(stub shim.ChaincodeStubInterface) ([]*Owner, error) {
+	resultsIterator, err := stub.GetStateByRange("", "")
+	if err != nil {
+		return nil, internalError(msgQueryFailed, "owners", err)
+	}
+	defer resultsIterator.Close()
+
+	var owners []*Owner
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError(msgReadFailed, "owners", err)
+		}
+		// A peer não devolve chaves compostas em range queries, mas stubs de teste como o shimtest devolvem
+		if strings.HasPrefix(queryResponse.Key, compositeKeyNamespace) {
+			continue
+		}
+		if documentType(queryResponse.Value) != docTypeOwner {
+			continue
+		}
+		var owner Owner
+		err = json.Unmarshal(queryResponse.Value, &owner)
+		if err != nil {
+			return nil, internalError(msgDeserializeFailed, "owner "+queryResponse.Key, err)
+		}
+		owners = append(owners, &owner)
+	}
+	return owners, nil
+}
@@ -0,0 +1,140 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Códigos estáveis dos erros devolvidos aos clientes. Clientes devem decidir pelo código, nunca pelo texto da mensagem
+type ErrorCode string
+
+const (
+	CodeInvalidArgument      ErrorCode = "INVALID_ARGUMENT"
+	CodeUnauthorized         ErrorCode = "UNAUTHORIZED"
+	CodeOwnerNotFound        ErrorCode = "OWNER_NOT_FOUND"
+	CodeMaterialNotFound     ErrorCode = "MATERIAL_NOT_FOUND"
+	CodeWandNotFound         ErrorCode = "WAND_NOT_FOUND"
+	CodeRecipeNotFound       ErrorCode = "RECIPE_NOT_FOUND"
+	CodeAlreadyExists        ErrorCode = "ALREADY_EXISTS"
+	CodeInsufficientQuantity ErrorCode = "INSUFFICIENT_QUANTITY"
+	CodeRecipeRetired        ErrorCode = "RECIPE_RETIRED"
+	CodeMigrationRequired    ErrorCode = "MIGRATION_REQUIRED"
+	CodeInternal             ErrorCode = "INTERNAL"
+)
+
+// Status no estilo HTTP de cada código, devolvido no Status da resposta do chaincode
+var errorStatus = map[ErrorCode]int32{
+	CodeInvalidArgument:      400,
+	CodeUnauthorized:         403,
+	CodeOwnerNotFound:        404,
+	CodeMaterialNotFound:     404,
+	CodeWandNotFound:         404,
+	CodeRecipeNotFound:       404,
+	CodeAlreadyExists:        409,
+	CodeInsufficientQuantity: 409,
+	CodeRecipeRetired:        409,
+	CodeMigrationRequired:    409,
+	CodeInternal:             500,
+}
+
+// Erro devolvido pelas transações. Vai serializado em JSON na mensagem da resposta, por exemplo
+// {"code":"OWNER_NOT_FOUND","status":404,"messageId":"owner.notFound","message":"Owner não existe: bob","details":{"owner":"bob"}}
+// Details guarda todos os valores usados no texto da mensagem, que é montado no idioma da invocação (ver catalog)
+type StudioError struct {
+	Code      ErrorCode         `json:"code"`
+	Status    int32             `json:"status"`
+	MessageID messageID         `json:"messageId"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+func newError(code ErrorCode, id messageID, details map[string]string) *StudioError {
+	return &StudioError{
+		Code:      code,
+		Status:    errorStatus[code],
+		MessageID: id,
+		Message:   renderMessage(defaultLanguage, id, details),
+		Details:   details,
+	}
+}
+
+// Falha inesperada da ledger ou de serialização ao lidar com item (por exemplo "owner bob")
+func internalError(id messageID, item string, err error) *StudioError {
+	return newError(CodeInternal, id, map[string]string{"item": item, "error": err.Error()})
+}
+
+// O texto do erro é o próprio JSON, porque é só o texto que o contractapi coloca na resposta
+func (e *StudioError) Error() string {
+	errorBytes, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(errorBytes)
+}
+
+// Erros que não são StudioError são falhas inesperadas (ledger, serialização) e viram INTERNAL
+func asStudioError(err error) *StudioError {
+	var studioErr *StudioError
+	if errors.As(err, &studioErr) {
+		return studioErr
+	}
+	return newError(CodeInternal, msgInternal, map[string]string{"error": err.Error()})
+}
+
+// Verifica o código de um erro, por exemplo para tratar um documento que não foi encontrado
+func hasCode(err error, code ErrorCode) bool {
+	var studioErr *StudioError
+	return errors.As(err, &studioErr) && studioErr.Code == code
+}
+
+// Acrescenta um detalhe ao erro, no lugar de prefixar a mensagem, que deixaria de ser JSON
+func withDetail(err error, key string, value string) error {
+	studioErr := asStudioError(err)
+	if studioErr.Details == nil {
+		studioErr.Details = make(map[string]string)
+	}
+	studioErr.Details[key] = value
+	return studioErr
+}
+
+// Resposta de erro com o status do código e a mensagem no idioma da invocação
+func errorResponse(err error, language string) pb.Response {
+	studioErr := asStudioError(err)
+	studioErr.Message = renderMessage(language, studioErr.MessageID, studioErr.Details)
+	return pb.Response{
+		Status:  studioErr.Status,
+		Message: studioErr.Error(),
+	}
+}
+
+// Erros que o contractapi gera antes de chamar a transação, todos sobre os argumentos recebidos
+var contractArgumentErrors = []string{
+	"Error managing parameter",
+	"Incorrect number of params",
+	"Contract not found",
+	"Blank function name",
+}
+
+// Troca o status 500 que o contractapi usa em todo erro pelo status do código.
+// Mensagens que não são um StudioError vêm do próprio contractapi ou de falhas inesperadas
+func structuredResponse(response pb.Response, language string) pb.Response {
+	if response.Status < shim.ERRORTHRESHOLD {
+		return response
+	}
+	var studioErr StudioError
+	err := json.Unmarshal([]byte(response.Message), &studioErr)
+	if err == nil && studioErr.Code != "" {
+		return errorResponse(&studioErr, language)
+	}
+	details := map[string]string{"error": response.Message}
+	for _, prefix := range contractArgumentErrors {
+		if strings.HasPrefix(response.Message, prefix) {
+			return errorResponse(newError(CodeInvalidArgument, msgContractArguments, details), language)
+		}
+	}
+	return errorResponse(newError(CodeInternal, msgInternal, details), language)
+}
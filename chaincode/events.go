@@ -0,0 +1,64 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Nomes dos eventos emitidos pelo chaincode. A Fabric guarda só um evento por transação,
+// então cada função que altera a ledger emite um único evento ao final
+const (
+	eventOwnerCreated          = "OwnerCreated"
+	eventOwnerBound            = "OwnerBound"
+	eventOwnersMigrated        = "OwnersMigrated"
+	eventMaterialMinted        = "MaterialMinted"
+	eventMaterialTransferred   = "MaterialTransferred"
+	eventWandCreated           = "WandCreated"
+	eventWandTransferred       = "WandTransferred"
+	eventRecipeRegistered      = "RecipeRegistered"
+	eventRecipeUpdated         = "RecipeUpdated"
+	eventRecipeRetired         = "RecipeRetired"
+	eventInventoryConsolidated = "InventoryConsolidated"
+	eventGenesisLoaded         = "GenesisLoaded"
+)
+
+// Payload JSON de todos os eventos:
+//
+//	type       nome do evento, igual ao nome registrado na Fabric
+//	txId       transação que gerou o evento
+//	owners     IDs dos owners envolvidos (sender primeiro nas transferências)
+//	from, to   sender e recipiente, só em transferências
+//	materiais  materiais cunhados, transferidos ou consumidos, com suas quantidades
+//	wands      IDs das varinhas criadas ou transferidas
+//	receita    receita usada ou alterada
+//	receitas   receitas registradas pelo Init
+type StudioEvent struct {
+	Type      string     `json:"type"`
+	TxID      string     `json:"txId"`
+	Owners    []string   `json:"owners"`
+	From      string     `json:"from,omitempty"`
+	To        string     `json:"to,omitempty"`
+	Materiais []Material `json:"materiais,omitempty"`
+	Wands     []string   `json:"wands,omitempty"`
+	Receita   string     `json:"receita,omitempty"`
+	Receitas  []string   `json:"receitas,omitempty"`
+}
+
+// Preenche o tipo e o TxID e registra o evento na transação
+func emitEvent(stub shim.ChaincodeStubInterface, eventType string, event StudioEvent) error {
+	event.Type = eventType
+	event.TxID = stub.GetTxID()
+	if event.Owners == nil {
+		event.Owners = []string{}
+	}
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return internalError(msgSerializeFailed, "event "+eventType, err)
+	}
+	err = stub.SetEvent(eventType, eventBytes)
+	if err != nil {
+		return newError(CodeInternal, msgEventFailed, map[string]string{"event": eventType, "error": err.Error()})
+	}
+	return nil
+}
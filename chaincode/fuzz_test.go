@@ -0,0 +1,111 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Alvos de fuzzing das entradas do chaincode: o Invoke (nome da função e argumentos), o Init (o documento de configuração)
+// e owners corrompidos na ledger. O go test roda só o corpus em testdata/fuzz;
+// para gerar entradas novas use, por exemplo, go test -run '^$' -fuzz FuzzInvoke -fuzztime 1m ./chaincode
+// Nenhuma entrada pode derrubar o chaincode, e toda resposta deve ser um sucesso ou um erro JSON cujo código
+// corresponde ao status (ver wellFormed)
+
+// Ledger com owners, materiais, uma receita e uma varinha, para que as entradas cheguem às transações e não parem no "owner não existe"
+func newFuzzHarness(t *testing.T, ids identities) *harness {
+	t.Helper()
+	h := newConfiguredHarness(t)
+	h.as(ids.alice).invoke("initOwner", "alice").ok()
+	h.as(ids.bob).invoke("initOwner", "bob").ok()
+	h.as(ids.alice).invoke("initMaterial", "ebano", "5", "alice").ok()
+	h.invoke("initMaterial", "rubi", "5", "alice").ok()
+	h.invoke("createWand", "alice").ok()
+	h.as(ids.admin).invoke("registerRecipe", "classica", `[{"descricao":"ebano","quantidade":1},{"descricao":"rubi","quantidade":2}]`).ok()
+	return h
+}
+
+// Os argumentos vêm em uma única string, separados por quebras de linha
+func FuzzInvoke(f *testing.F) {
+	ids := newIdentities(f)
+	f.Fuzz(func(t *testing.T, function string, args string) {
+		h := newFuzzHarness(t, ids)
+		invocation := append([]string{function}, strings.Split(args, "\n")...)
+		if args == "" {
+			invocation = []string{function}
+		}
+		h.as(ids.alice).invoke(invocation...).wellFormed()
+		h.as(ids.admin).invoke(invocation...).wellFormed()
+	})
+}
+
+func FuzzInit(f *testing.F) {
+	f.Fuzz(func(t *testing.T, config string) {
+		h := newHarness(t)
+		if h.init(config).wellFormed() {
+			// Uma configuração aceita precisa servir às transações seguintes
+			h.invoke("getMaterials").wellFormed()
+		}
+	})
+}
+
+// Documento de owner corrompido na ledger (JSON inválido, tipos errados, listas legadas estranhas),
+// seguido de todas as transações que leem ou escrevem esse owner
+func FuzzCorruptedOwner(f *testing.F) {
+	ids := newIdentities(f)
+	f.Fuzz(func(t *testing.T, document string) {
+		h := newFuzzHarness(t, ids)
+		h.putRaw("carol", document)
+
+		h.as(ids.alice).invoke("QueryOwner", "carol").wellFormed()
+		h.invoke("initOwner", "carol").wellFormed()
+		h.invoke("initMaterial", "ebano", "1", "carol").wellFormed()
+		h.invoke("swapMaterials", "alice", "ebano", "1", "carol").wellFormed()
+		h.invoke("swapMaterials", "carol", "ebano", "1", "alice").wellFormed()
+		h.invoke("createWand", "carol").wellFormed()
+		h.invoke("createWand", "carol", "classica", "1").wellFormed()
+		h.invoke("getWandsByOwner", "carol").wellFormed()
+		h.invoke("getHistory", "carol").wellFormed()
+		h.invoke("getHistory", "carol", "ebano").wellFormed()
+		h.invoke("getMaterials").wellFormed()
+		h.invoke("getWands").wellFormed()
+		h.as(ids.admin).invoke("bootstrapOwner", "carol", "Org1MSP", "client").wellFormed()
+		h.invoke("consolidateInventory", "carol").wellFormed()
+		h.invoke("migrateOwners", "carol").wellFormed()
+		h.invoke("migrateOwners").wellFormed()
+		h.as(ids.alice).invoke("QueryOwner", "carol").wellFormed()
+	})
+}
+
+// Verifica que a resposta é um sucesso ou um erro JSON com código, status e mensagem coerentes.
+// Retorna se a chamada teve sucesso
+func (r *result) wellFormed() bool {
+	r.t.Helper()
+	if r.response.Status == shim.OK {
+		if r.response.Message != "" {
+			r.t.Fatalf("%q: success with message %q", r.args, r.response.Message)
+		}
+		return true
+	}
+	var studioErr StudioError
+	err := json.Unmarshal([]byte(r.response.Message), &studioErr)
+	if err != nil {
+		r.t.Fatalf("%q: expected a JSON error, got %d: %q", r.args, r.response.Status, r.response.Message)
+	}
+	status, ok := errorStatus[studioErr.Code]
+	if !ok {
+		r.t.Fatalf("%q: unknown error code %q", r.args, studioErr.Code)
+	}
+	if r.response.Status != status || studioErr.Status != status {
+		r.t.Fatalf("%q: %s with status %d/%d, expected %d", r.args, studioErr.Code, r.response.Status, studioErr.Status, status)
+	}
+	if studioErr.MessageID == "" || studioErr.Message == "" {
+		r.t.Fatalf("%q: error without message: %q", r.args, r.response.Message)
+	}
+	if len(r.response.Payload) != 0 {
+		r.t.Fatalf("%q: error with payload %q", r.args, r.response.Payload)
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Documento JSON recebido pelo Init: a configuração (roles e language, ver StudioConfig) e o estado inicial da ledger.
+// Todas as partes são opcionais, por exemplo
+// {"roles":{...},"owners":[{"id":"alice","mspId":"Org1MSP","clientId":"...","materiais":[{"descricao":"ebano","quantidade":3}]}],
+// "recipes":[{"id":"classica","materiais":[{"descricao":"ebano","quantidade":1},{"descricao":"rubi","quantidade":2}]}]}
+// O Init pode ser repetido, por exemplo em um upgrade, com o mesmo documento: roles e language presentes substituem os da
+// configuração gravada, e os ausentes ficam como estavam. Owners e receitas que já existem na ledger ficam como estão,
+// então nenhum material é cunhado duas vezes
+type Genesis struct {
+	Roles    map[string]RoleRule `json:"roles"`
+	Language string              `json:"language,omitempty"`
+	Owners   []GenesisOwner      `json:"owners"`
+	Recipes  []GenesisRecipe     `json:"recipes"`
+}
+
+// Owner criado pelo Init, com o estoque inicial de materiais.
+// Sem mspId e clientId o owner fica sem vínculo até um admin chamar o bootstrapOwner
+type GenesisOwner struct {
+	Id        string            `json:"id"`
+	MSPID     string            `json:"mspId"`
+	ClientID  string            `json:"clientId"`
+	Materiais []GenesisMaterial `json:"materiais"`
+
+	// Materiais já validados e juntados pela descrição canônica
+	materials []Material
+}
+
+type GenesisMaterial struct {
+	Descricao  string `json:"descricao"`
+	Quantidade int    `json:"quantidade"`
+}
+
+// Receita registrada pelo Init, ativa
+type GenesisRecipe struct {
+	Id        string           `json:"id"`
+	Materiais []RecipeMaterial `json:"materiais"`
+}
+
+// Lê e valida o documento do Init inteiro antes de qualquer escrita na ledger.
+// Campos desconhecidos são recusados, para que um erro de digitação não descarte parte do estado inicial
+func parseGenesis(genesisJSON string) (*Genesis, error) {
+	var genesis Genesis
+	decoder := json.NewDecoder(bytes.NewReader([]byte(genesisJSON)))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&genesis)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after the document")
+	}
+	if err != nil {
+		return nil, newError(CodeInvalidArgument, msgConfigInvalid, map[string]string{"error": err.Error()})
+	}
+
+	err = validateConfig(genesis.config())
+	if err != nil {
+		return nil, err
+	}
+
+	ownerIDs := make(map[string]bool)
+	for i := range genesis.Owners {
+		owner := &genesis.Owners[i]
+		err = validateGenesisOwner(owner)
+		if err != nil {
+			return nil, err
+		}
+		if ownerIDs[owner.Id] {
+			return nil, newError(CodeInvalidArgument, msgConfigDuplicate, map[string]string{"kind": "owner", "id": owner.Id})
+		}
+		ownerIDs[owner.Id] = true
+	}
+
+	recipeIDs := make(map[string]bool)
+	for i := range genesis.Recipes {
+		recipe := &genesis.Recipes[i]
+		err = validate(
+			validateID("recipe", recipe.Id),
+			validateRecipeMaterials(recipe.Materiais),
+		)
+		if err != nil {
+			return nil, withDetail(err, "recipe", recipe.Id)
+		}
+		if recipeIDs[recipe.Id] {
+			return nil, newError(CodeInvalidArgument, msgConfigDuplicate, map[string]string{"kind": "recipe", "id": recipe.Id})
+		}
+		recipeIDs[recipe.Id] = true
+		recipe.Materiais = canonicalRecipeMaterials(recipe.Materiais)
+	}
+
+	return &genesis, nil
+}
+
+func validateGenesisOwner(owner *GenesisOwner) error {
+	err := validateID("owner", owner.Id)
+	if err != nil {
+		return err
+	}
+	if (owner.MSPID == "") != (owner.ClientID == "") {
+		return newError(CodeInvalidArgument, msgConfigOwnerBinding, map[string]string{"owner": owner.Id})
+	}
+	if owner.MSPID != "" {
+		err = validate(
+			validateID("mspId", owner.MSPID),
+			validateNotEmpty("clientId", owner.ClientID),
+		)
+		if err != nil {
+			return withDetail(err, "owner", owner.Id)
+		}
+	}
+
+	var materials []Material
+	for _, material := range owner.Materiais {
+		err = validate(
+			validateDescription(material.Descricao),
+			validateQuantity("quantidade", material.Quantidade),
+		)
+		if err != nil {
+			return withDetail(err, "owner", owner.Id)
+		}
+		materials = append(materials, Material{Descricao: material.Descricao, Quantidade: material.Quantidade})
+	}
+	owner.materials, err = mergeMaterials(owner.Id, materials)
+	return err
+}
+
+func (genesis *Genesis) config() *StudioConfig {
+	return &StudioConfig{
+		ObjectType: docTypeConfig,
+		Roles:      genesis.Roles,
+		Language:   genesis.Language,
+	}
+}
+
+// Junta roles e language do documento à configuração gravada. Um documento sem eles (só owners ou receitas) não toca
+// na configuração: gravá-la sem roles deixaria todos os papéis sem regra, e um upgrade que só acrescenta owners apagaria os papéis
+func mergeConfig(stub shim.ChaincodeStubInterface, genesis *Genesis) error {
+	if genesis.Roles == nil && genesis.Language == "" {
+		return nil
+	}
+	config, err := getConfig(stub)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		config = &StudioConfig{ObjectType: docTypeConfig}
+	}
+	if genesis.Roles != nil {
+		config.Roles = genesis.Roles
+	}
+	if genesis.Language != "" {
+		config.Language = genesis.Language
+	}
+	return putConfig(stub, config)
+}
+
+// Grava a configuração, se o documento tem roles ou language, e cria os owners, com seus materiais, e as receitas que ainda
+// não existem na ledger. O Init é uma única transação: se algo falhar, nada do documento é gravado
+func loadGenesis(stub shim.ChaincodeStubInterface, genesis *Genesis) error {
+	err := mergeConfig(stub, genesis)
+	if err != nil {
+		return err
+	}
+
+	repo := newRepository(stub)
+	event := StudioEvent{}
+	for _, genesisOwner := range genesis.Owners {
+		exists, err := repo.ownerExists(genesisOwner.Id)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		owner := Owner{
+			ObjectType: docTypeOwner,
+			Id:         genesisOwner.Id,
+			MSPID:      genesisOwner.MSPID,
+			ClientID:   genesisOwner.ClientID,
+		}
+		err = putOwner(stub, &owner)
+		if err != nil {
+			return err
+		}
+		for i := range genesisOwner.materials {
+			err = putMaterial(stub, &genesisOwner.materials[i])
+			if err != nil {
+				return err
+			}
+		}
+		event.Owners = append(event.Owners, owner.Id)
+		event.Materiais = append(event.Materiais, genesisOwner.materials...)
+	}
+
+	for _, genesisRecipe := range genesis.Recipes {
+		exists, err := repo.recipeExists(genesisRecipe.Id)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		recipe := Recipe{
+			ObjectType: docTypeRecipe,
+			Id:         genesisRecipe.Id,
+			Materiais:  genesisRecipe.Materiais,
+			Ativa:      true,
+		}
+		err = putRecipe(stub, &recipe)
+		if err != nil {
+			return err
+		}
+		event.Receitas = append(event.Receitas, recipe.Id)
+	}
+
+	if len(event.Owners) == 0 && len(event.Receitas) == 0 {
+		return nil
+	}
+	return emitEvent(stub, eventGenesisLoaded, event)
+}
@@ -0,0 +1,156 @@
+package chaincode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Documento do Init com a configuração de testConfig, alice vinculada à sua identidade, carol sem vínculo e uma receita
+func genesisDocument(t *testing.T, ids identities, owners ...string) string {
+	t.Helper()
+	h := newHarness(t)
+	h.as(ids.alice).invoke("initOwner", "alice").ok()
+	var alice Owner
+	h.invoke("QueryOwner", "alice").decode(&alice)
+
+	all := map[string]string{
+		"alice": `{"id":"alice","mspId":"` + alice.MSPID + `","clientId":"` + alice.ClientID + `",` +
+			`"materiais":[{"descricao":"ebano","quantidade":3},{"descricao":"rubi","quantidade":2},{"descricao":"rubi","quantidade":2}]}`,
+		"carol": `{"id":"carol","materiais":[{"descricao":"pena  de fênix","quantidade":1}]}`,
+	}
+	var documents []string
+	for _, owner := range owners {
+		documents = append(documents, all[owner])
+	}
+	return testConfig[:len(testConfig)-1] + `,"owners":[` + strings.Join(documents, ",") + `],` +
+		`"recipes":[{"id":"classica","materiais":[{"descricao":"ebano","quantidade":1},{"descricao":"rubi","quantidade":2}]}]}`
+}
+
+func TestGenesis(t *testing.T) {
+	ids := newIdentities(t)
+	h := newHarness(t)
+	genesis := genesisDocument(t, ids, "alice", "carol")
+
+	h.init(genesis).ok()
+	event := h.lastEvent(eventGenesisLoaded)
+	if !reflect.DeepEqual(event.Owners, []string{"alice", "carol"}) || !reflect.DeepEqual(event.Receitas, []string{"classica"}) {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if descriptions(event.Materiais) != "ebano=3,pena de fênix=1,rubi=4" {
+		t.Fatalf("unexpected event materials %+v", event.Materiais)
+	}
+	if h.quantity("alice", "ebano") != 3 || h.quantity("alice", "rubi") != 4 || h.quantity("carol", "pena de fênix") != 1 {
+		t.Fatalf("genesis materials were not minted")
+	}
+
+	// alice já pode usar o estoque e a receita; carol espera o bootstrapOwner de um admin
+	h.as(ids.alice).invoke("createWand", "alice", "classica", "1").ok()
+	h.invoke("initMaterial", "ebano", "1", "carol").failsWith(CodeUnauthorized, msgAccessUnboundOwner)
+	h.as(ids.admin).invoke("registerRecipe", "classica", `[{"descricao":"ebano","quantidade":1}]`).failsWith(CodeAlreadyExists, msgRecipeExists)
+
+	// Repetir o Init, como em um upgrade, não cunha os materiais de novo nem desfaz o que as transações mudaram
+	state := len(h.stub.State)
+	h.init(genesis).ok()
+	if h.event != nil {
+		t.Fatalf("expected no event, got %s", h.event.EventName)
+	}
+	if len(h.stub.State) != state || h.quantity("alice", "ebano") != 2 || h.quantity("alice", "rubi") != 2 {
+		t.Fatalf("repeated genesis changed the ledger")
+	}
+}
+
+// Um upgrade pode acrescentar owners ao documento; só os novos são criados
+func TestGenesisUpgrade(t *testing.T) {
+	ids := newIdentities(t)
+	h := newHarness(t)
+
+	h.init(genesisDocument(t, ids, "alice")).ok()
+	h.as(ids.alice).invoke("swapMaterials", "alice", "ebano", "3", "bob").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	h.init(genesisDocument(t, ids, "alice", "carol")).ok()
+	event := h.lastEvent(eventGenesisLoaded)
+	if !reflect.DeepEqual(event.Owners, []string{"carol"}) || len(event.Receitas) != 0 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if h.quantity("alice", "ebano") != 3 || h.quantity("carol", "pena de fênix") != 1 {
+		t.Fatalf("unexpected materials after the upgrade")
+	}
+
+	// Uma chave com outro tipo de documento também é pulada, sem ser sobrescrita
+	h = newHarness(t)
+	h.putRaw("carol", `{"docType":"recipe","id":"carol"}`)
+	h.init(genesisDocument(t, ids, "carol")).ok()
+	if string(h.stub.State["carol"]) != `{"docType":"recipe","id":"carol"}` || h.quantity("carol", "pena de fênix") != 0 {
+		t.Fatalf("the foreign document was overwritten")
+	}
+}
+
+// Um documento sem roles não apaga os papéis nem fecha as transações que dependem deles
+func TestGenesisWithoutRoles(t *testing.T) {
+	ids := newIdentities(t)
+	recipe := `{"recipes":[{"id":"classica","materiais":[{"descricao":"ebano","quantidade":1}]}]}`
+
+	// Sem configuração nenhuma, só com receitas ou só com o idioma, os papéis continuam sem verificação
+	for _, genesis := range []string{recipe, `{"language":"en"}`} {
+		h := newHarness(t)
+		h.init(genesis).ok()
+		h.as(ids.bob).invoke("initOwner", "bob").ok()
+		h.invoke("initMaterial", "ebano", "1", "bob").ok()
+		h.invoke("initMaterial", "rubi", "1", "bob").ok()
+		h.invoke("createWand", "bob").ok()
+		h.invoke("migrateOwners").failsWith(CodeUnauthorized, msgAccessNoRule)
+	}
+
+	// Com papéis já configurados, um upgrade só com owners, receitas ou idioma mantém os papéis
+	h := newConfiguredHarness(t)
+	h.init(recipe).ok()
+	h.init(`{"owners":[{"id":"carol"}]}`).ok()
+	h.init(`{"language":"en"}`).ok()
+	h.as(ids.alice).invoke("initOwner", "alice").ok()
+	h.invoke("initMaterial", "ebano", "2", "alice").ok()
+	h.invoke("createWand", "alice", "classica", "1").ok()
+	h.as(ids.admin).invoke("bootstrapOwner", "carol", "Org1MSP", "client-carol").ok()
+	studioErr := h.as(ids.bob).invoke("createWand", "bob").failsWith(CodeUnauthorized, msgAccessMissingRole)
+	if !strings.HasPrefix(studioErr.Message, "Access denied") {
+		t.Fatalf("expected the message in the configured language, got %q", studioErr.Message)
+	}
+
+	// Roles presentes substituem os gravados
+	h.init(`{"roles":{"admin":{"mspIds":["Org0MSP"]}}}`).ok()
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "alice").failsWith(CodeUnauthorized, msgAccessNoRule)
+}
+
+// Um documento inválido é recusado inteiro, sem gravar nada
+func TestGenesisInvalid(t *testing.T) {
+	h := newHarness(t)
+
+	for _, test := range []struct {
+		genesis string
+		id      messageID
+		details map[string]string
+	}{
+		{`{"owners":[{"id":"alice"},{"id":"alice"}]}`, msgConfigDuplicate, map[string]string{"kind": "owner", "id": "alice"}},
+		{`{"recipes":[{"id":"r","materiais":[{"descricao":"ebano","quantidade":1}]},{"id":"r","materiais":[{"descricao":"rubi","quantidade":1}]}]}`,
+			msgConfigDuplicate, map[string]string{"kind": "recipe", "id": "r"}},
+		{`{"owners":[{"id":"alice","mspId":"Org1MSP"}]}`, msgConfigOwnerBinding, map[string]string{"owner": "alice"}},
+		{`{"owners":[{"id":"alice","materiais":[{"descricao":"ebano","quantidade":0}]}]}`, msgQuantityNotPositive, map[string]string{"owner": "alice"}},
+		{`{"owners":[{"id":"alice","materiais":[{"descricao":"","quantidade":1}]}]}`, msgDescriptionEmpty, map[string]string{"owner": "alice"}},
+		{`{"owners":[{"id":"alice","materiais":[{"descricao":"ebano","quantidade":9223372036854775807},{"descricao":"ebano","quantidade":1}]}]}`,
+			msgQuantityOverflow, map[string]string{"owner": "alice", "material": "ebano"}},
+		{`{"owners":[{"id":"a~b"}]}`, msgIDInvalid, nil},
+		{`{"recipes":[{"id":"r","materiais":[]}]}`, msgRecipeEmpty, map[string]string{"recipe": "r"}},
+		{`{"owner":[{"id":"alice"}]}`, msgConfigInvalid, nil},
+		{`{"owners":[{"id":"alice","wands":[]}]}`, msgConfigInvalid, nil},
+		{`{} {}`, msgConfigInvalid, nil},
+	} {
+		studioErr := h.init(test.genesis).failsWith(CodeInvalidArgument, test.id)
+		for key, value := range test.details {
+			if studioErr.Details[key] != value {
+				t.Fatalf("%s: expected detail %s=%s, got %v", test.genesis, key, value, studioErr.Details)
+			}
+		}
+		if len(h.stub.State) != 0 {
+			t.Fatalf("%s: the ledger was changed", test.genesis)
+		}
+	}
+}
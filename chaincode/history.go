@@ -0,0 +1,192 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Timestamps com largura fixa, em UTC. São os timestamps das propostas, escolhidos pelos clientes, então só informam:
+// a ordem do histórico é a ordem em que a peer gravou as transações
+const historyTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Uma versão passada de uma chave da ledger
+// Owner indica de qual owner era a chave, nas chaves de materiais e varinhas
+type HistoryEntry struct {
+	TxID      string      `json:"txId"`
+	Timestamp string      `json:"timestamp"`
+	IsDelete  bool        `json:"isDelete"`
+	Owner     string      `json:"owner,omitempty" metadata:",optional"`
+	Value     interface{} `json:"value"`
+}
+
+// Lê todas as versões de uma chave, da mais recente para a mais antiga, como o GetHistoryForKey da peer as devolve
+func keyHistory(stub shim.ChaincodeStubInterface, key string, ownerID string) ([]HistoryEntry, error) {
+	resultsIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, internalError(msgQueryFailed, "history", err)
+	}
+	defer resultsIterator.Close()
+
+	var history []HistoryEntry
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError(msgReadFailed, "history", err)
+		}
+		entry := HistoryEntry{
+			TxID:     modification.TxId,
+			IsDelete: modification.IsDelete,
+			Owner:    ownerID,
+		}
+		if modification.Timestamp != nil {
+			entry.Timestamp = modification.Timestamp.AsTime().UTC().Format(historyTimeFormat)
+		}
+		// Versões apagadas não têm valor. O valor vai como JSON genérico, que a metadata do contrato aceita para qualquer documento
+		if !modification.IsDelete && json.Valid(modification.Value) {
+			err = json.Unmarshal(modification.Value, &entry.Value)
+			if err != nil {
+				return nil, internalError(msgDeserializeFailed, "history", err)
+			}
+		}
+		history = append(history, entry)
+	}
+	return history, nil
+}
+
+// Histórico de uma varinha em todos os owners por onde ela passou, da versão mais recente para a mais antiga.
+// O histórico do índice wandId~wandID, que não muda de chave nas transferências, diz quem recebeu a varinha em cada transação;
+// cada período de um owner vai da versão mais recente da sua chave até a transação em que ele recebeu a varinha.
+// Numa transferência a criação no novo owner vem antes da remoção no anterior, que é da mesma transação
+func wandHistory(stub shim.ChaincodeStubInterface, wandID string) ([]HistoryEntry, error) {
+	indexKey, err := wandIDKey(stub, wandID)
+	if err != nil {
+		return nil, internalError(msgKeyFailed, "wand "+wandID, err)
+	}
+	resultsIterator, err := stub.GetHistoryForKey(indexKey)
+	if err != nil {
+		return nil, internalError(msgQueryFailed, "history "+wandID, err)
+	}
+	defer resultsIterator.Close()
+
+	type period struct {
+		ownerID string
+		txID    string
+	}
+	var periods []period
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError(msgReadFailed, "history "+wandID, err)
+		}
+		if modification.IsDelete {
+			continue
+		}
+		periods = append(periods, period{ownerID: string(modification.Value), txID: modification.TxId})
+	}
+
+	// Histórico da chave da varinha em cada owner, lido uma vez só mesmo que a varinha volte ao owner
+	ownerHistories := make(map[string][]HistoryEntry)
+	var owners []string
+	for _, p := range periods {
+		if _, ok := ownerHistories[p.ownerID]; ok {
+			continue
+		}
+		key, err := wandKey(stub, p.ownerID, wandID)
+		if err != nil {
+			return nil, internalError(msgKeyFailed, "wand "+wandID, err)
+		}
+		ownerHistory, err := keyHistory(stub, key, p.ownerID)
+		if err != nil {
+			return nil, err
+		}
+		ownerHistories[p.ownerID] = ownerHistory
+		owners = append(owners, p.ownerID)
+	}
+
+	var history []HistoryEntry
+	for _, p := range periods {
+		ownerHistory := ownerHistories[p.ownerID]
+		i := 0
+		for i < len(ownerHistory) {
+			i++
+			if ownerHistory[i-1].TxID == p.txID {
+				break
+			}
+		}
+		history = append(history, ownerHistory[:i]...)
+		ownerHistories[p.ownerID] = ownerHistory[i:]
+	}
+	// Versões fora dos períodos do índice (não deveria haver) não são descartadas
+	for _, ownerID := range owners {
+		history = append(history, ownerHistories[ownerID]...)
+	}
+	return history, nil
+}
+
+// Get history retorna todas as versões passadas de uma chave, com TxID, timestamp e se a versão foi uma remoção,
+// da mais recente para a mais antiga
+// Tem como entrada o ID de uma varinha (histórico da varinha em todos os owners por onde passou)
+// ou o ID de um owner (histórico do documento do owner). IDs que não são nem varinha nem owner devolvem OWNER_NOT_FOUND
+func (c *StudioContract) GetHistory(ctx StudioContextInterface, id string) ([]HistoryEntry, error) {
+	err := validateIDReference("id", id)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	repo := ctx.Repository()
+	_, err = repo.findWand(id)
+	if err != nil && !hasCode(err, CodeWandNotFound) {
+		return nil, err
+	}
+	var history []HistoryEntry
+	if err == nil {
+		history, err = wandHistory(stub, id)
+	} else {
+		_, err = repo.findOwner(id)
+		if err != nil {
+			return nil, err
+		}
+		history, err = keyHistory(stub, id, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []HistoryEntry{}
+	}
+	return history, nil
+}
+
+// Get material history retorna o histórico de um material de um owner
+// Tem como entrada o ID do owner e a descrição do material
+func (c *StudioContract) GetMaterialHistory(ctx StudioContextInterface, ownerID string, descricao string) ([]HistoryEntry, error) {
+	err := validate(
+		validateIDReference("owner", ownerID),
+		validateDescriptionReference(descricao),
+	)
+	if err != nil {
+		return nil, err
+	}
+	descricao = canonicalDescription(descricao)
+
+	_, err = ctx.Repository().findOwner(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	key, err := materialKey(stub, ownerID, descricao)
+	if err != nil {
+		return nil, internalError(msgKeyFailed, "material "+descricao, err)
+	}
+	history, err := keyHistory(stub, key, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []HistoryEntry{}
+	}
+	return history, nil
+}
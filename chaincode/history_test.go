@@ -0,0 +1,64 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWandHistory(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "alice").ok()
+	h.invoke("initMaterial", "rubi", "1", "alice").ok()
+	var wand Wand
+	h.invoke("createWand", "alice").decode(&wand)
+	h.invoke("transferWand", "alice", wand.Id, "bob").ok()
+	h.as(ids.bob).invoke("transferWand", "bob", wand.Id, "alice").ok()
+
+	// Da mais recente para a mais antiga: volta para alice, saída de bob, chegada em bob, saída de alice, criação em alice
+	var history []HistoryEntry
+	h.invoke("getHistory", wand.Id).decode(&history)
+	var got []string
+	for _, entry := range history {
+		if entry.TxID == "" || entry.Timestamp == "" {
+			t.Fatalf("entry without TxID or timestamp: %+v", entry)
+		}
+		operation := "put"
+		if entry.IsDelete {
+			operation = "delete"
+		}
+		got = append(got, operation+" "+entry.Owner)
+	}
+	expected := []string{"put alice", "delete bob", "put bob", "delete alice", "put alice"}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	// Cada transferência é uma transação só, e a criação é a transação do ID da varinha
+	if history[0].TxID != history[1].TxID || history[2].TxID != history[3].TxID || !strings.HasPrefix(wand.Id, history[4].TxID+"-") {
+		t.Fatalf("unexpected transactions %+v", history)
+	}
+}
+
+func TestOwnerAndMaterialHistory(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "2", "alice").ok()
+	h.invoke("swapMaterials", "alice", "ebano", "2", "bob").ok()
+
+	var history []HistoryEntry
+	h.invoke("getHistory", "alice").decode(&history)
+	if len(history) != 1 || history[0].IsDelete {
+		t.Fatalf("unexpected owner history %+v", history)
+	}
+
+	h.invoke("getHistory", "alice", "ebano").decode(&history)
+	if len(history) != 2 || !history[0].IsDelete || history[1].IsDelete || history[0].Owner != "alice" {
+		t.Fatalf("unexpected material history %+v", history)
+	}
+	h.invoke("getHistory", "alice", "rubi").decode(&history)
+	if len(history) != 0 {
+		t.Fatalf("unexpected material history %+v", history)
+	}
+
+	h.invoke("getHistory", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	h.invoke("getHistory", "nobody", "ebano").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	h.invoke("getHistory", "").failsWith(CodeInvalidArgument, msgIDEmpty)
+}
@@ -0,0 +1,55 @@
+package chaincode
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Identidade do cliente que submeteu a transação, lida do certificado X.509 do proposal
+type callerIdentity struct {
+	MSPID string
+	ID    string
+}
+
+// O ID do cid vem em base64 ("x509::<subject>::<issuer>"); decodificado fica legível nas mensagens de erro
+func (c *callerIdentity) String() string {
+	decoded, err := base64.StdEncoding.DecodeString(c.ID)
+	if err != nil {
+		return fmt.Sprintf("%s (%s)", c.ID, c.MSPID)
+	}
+	return fmt.Sprintf("%s (%s)", decoded, c.MSPID)
+}
+
+func getCaller(stub shim.ChaincodeStubInterface) (*callerIdentity, error) {
+	clientIdentity, err := cid.New(stub)
+	if err != nil {
+		return nil, internalError(msgIdentityFailed, "identity", err)
+	}
+	mspID, err := clientIdentity.GetMSPID()
+	if err != nil {
+		return nil, internalError(msgIdentityFailed, "MSP ID", err)
+	}
+	id, err := clientIdentity.GetID()
+	if err != nil {
+		return nil, internalError(msgIdentityFailed, "ID", err)
+	}
+	return &callerIdentity{MSPID: mspID, ID: id}, nil
+}
+
+// Verifica se quem submeteu a transação é a identidade vinculada ao owner no initOwner
+func assertCallerIsOwner(stub shim.ChaincodeStubInterface, owner *Owner) error {
+	if owner.MSPID == "" || owner.ClientID == "" {
+		return newError(CodeUnauthorized, msgAccessUnboundOwner, map[string]string{"owner": owner.Id})
+	}
+	caller, err := getCaller(stub)
+	if err != nil {
+		return err
+	}
+	if caller.MSPID != owner.MSPID || caller.ID != owner.ClientID {
+		return newError(CodeUnauthorized, msgAccessNotOwner, map[string]string{"owner": owner.Id, "caller": caller.String(), "mspId": caller.MSPID})
+	}
+	return nil
+}
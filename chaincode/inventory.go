@@ -0,0 +1,118 @@
+package chaincode
+
+import (
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Estoque de materiais de um owner durante uma transação.
+// Os materiais são lidos sob demanda e as alterações ficam em memória até o save, porque a ledger
+// não enxerga as escritas da própria transação: cada chave alterada é escrita uma única vez.
+// Entradas que chegam a zero são removidas da ledger no save
+type inventory struct {
+	stub      shim.ChaincodeStubInterface
+	ownerID   string
+	materials map[string]*Material
+	changed   []string
+}
+
+func newInventory(stub shim.ChaincodeStubInterface, ownerID string) *inventory {
+	return &inventory{
+		stub:      stub,
+		ownerID:   ownerID,
+		materials: make(map[string]*Material),
+	}
+}
+
+// Retorna o material do owner, com quantidade zero se ele não possui o material
+func (inv *inventory) get(descricao string) (*Material, error) {
+	if material, ok := inv.materials[descricao]; ok {
+		return material, nil
+	}
+	material, err := getMaterial(inv.stub, inv.ownerID, descricao)
+	if err != nil {
+		return nil, err
+	}
+	if material == nil {
+		material = &Material{
+			ObjectType: docTypeMaterial,
+			Descricao:  descricao,
+			Quantidade: 0,
+			Owner:      inv.ownerID,
+		}
+	}
+	inv.materials[descricao] = material
+	return material, nil
+}
+
+func (inv *inventory) markChanged(descricao string) {
+	for _, changed := range inv.changed {
+		if changed == descricao {
+			return
+		}
+	}
+	inv.changed = append(inv.changed, descricao)
+}
+
+// Quantidade que o owner possui do material
+func (inv *inventory) quantity(descricao string) (int, error) {
+	material, err := inv.get(descricao)
+	if err != nil {
+		return 0, err
+	}
+	return material.Quantidade, nil
+}
+
+// Soma quantidade ao material do owner, criando a entrada se ela não existe
+func (inv *inventory) add(descricao string, quantidade int) error {
+	material, err := inv.get(descricao)
+	if err != nil {
+		return err
+	}
+	total, ok := addQuantities(material.Quantidade, quantidade)
+	if !ok {
+		return quantityOverflowError(inv.ownerID, descricao)
+	}
+	material.Quantidade = total
+	inv.markChanged(descricao)
+	return nil
+}
+
+// Retira quantidade do material do owner. Falha sem alterar nada se o estoque não é suficiente
+func (inv *inventory) consume(descricao string, quantidade int) error {
+	material, err := inv.get(descricao)
+	if err != nil {
+		return err
+	}
+	if material.Quantidade < quantidade {
+		details := map[string]string{
+			"owner":     inv.ownerID,
+			"material":  descricao,
+			"available": strconv.Itoa(material.Quantidade),
+			"required":  strconv.Itoa(quantidade),
+		}
+		return newError(CodeInsufficientQuantity, msgInsufficientQuantity, details)
+	}
+	material.Quantidade -= quantidade
+	inv.markChanged(descricao)
+	return nil
+}
+
+// Grava na ledger os materiais alterados. Materiais zerados são apagados
+func (inv *inventory) save() error {
+	for _, descricao := range inv.changed {
+		material := inv.materials[descricao]
+		var err error
+		if material.Quantidade == 0 {
+			err = deleteMaterial(inv.stub, material)
+		} else {
+			err = putMaterial(inv.stub, material)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	inv.changed = nil
+	return nil
+}
@@ -0,0 +1,283 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Chaincode Studio entregue à peer. Encaminha as transações para o StudioContract e mantém
+// as chamadas antigas dos scripts do minifab funcionando: o contractapi já aceita o nome com a
+// primeira letra minúscula (initOwner -> InitOwner), e aqui são traduzidas as formas que
+// dependiam da quantidade de argumentos e a forma JSON (ver requestSpecs)
+type StudioChaincode struct {
+	contract   *contractapi.ContractChaincode
+	schemas    requestSchemas
+	parameters map[string]int
+}
+
+// Nome do contrato na metadata e na forma qualificada das chamadas (StudioContract:InitOwner)
+const contractName = "StudioContract"
+
+// Transação do contrato de sistema do contractapi que devolve a metadata
+const metadataFunction = contractapi.SystemContractName + ":GetMetadata"
+
+// Descrição do chaincode e do StudioContract na metadata
+var studioInfo = metadata.InfoMetadata{
+	Title:       "Studio",
+	Description: "Transferencia de materiais e produção de varinhas",
+	Version:     "latest",
+}
+
+// Cria o chaincode com o StudioContract e a metadata gerada a partir dos seus métodos
+func NewStudioChaincode() (*StudioChaincode, error) {
+	contract := new(StudioContract)
+	contract.Name = contractName
+	contract.Info = studioInfo
+	contract.TransactionContextHandler = new(StudioContext)
+	contract.UnknownTransaction = unknownTransaction
+
+	contractChaincode, err := contractapi.NewChaincode(contract)
+	if err != nil {
+		return nil, err
+	}
+	// O NewChaincode gera a metadata antes que o Info possa ser preenchido, então o Invoke também o aplica à resposta (ver withInfo)
+	contractChaincode.Info = studioInfo
+	schemas, err := compileRequestSchemas()
+	if err != nil {
+		return nil, err
+	}
+
+	return &StudioChaincode{contract: contractChaincode, schemas: schemas, parameters: transactionParameters(contract)}, nil
+}
+
+// Número de argumentos de cada transação do contrato, sem o contexto. O contractapi só recusa argumentos a menos
+// e ignora os que sobram, então o Invoke confere a quantidade antes (ver checkArguments)
+func transactionParameters(contract *StudioContract) map[string]int {
+	contextType := reflect.TypeOf((*StudioContextInterface)(nil)).Elem()
+	contractType := reflect.TypeOf(contract)
+	parameters := make(map[string]int)
+	for i := 0; i < contractType.NumMethod(); i++ {
+		method := contractType.Method(i)
+		// O primeiro parâmetro depois do receiver é o contexto; os métodos do contractapi.Contract não o recebem
+		if method.Type.NumIn() < 2 || method.Type.In(1) != contextType {
+			continue
+		}
+		parameters[method.Name] = method.Type.NumIn() - 2
+	}
+	return parameters
+}
+
+// Recusa chamadas com argumentos a mais ou a menos para a transação. O nome pode vir com a primeira letra minúscula
+// ou qualificado com o nome do contrato, como o contractapi aceita; nomes desconhecidos seguem para o unknownTransaction.
+// function e args são a chamada já traduzida pelo legacyCall; o erro cita o nome que o cliente chamou e todas as formas que ele aceita
+func (cc *StudioChaincode) checkArguments(called string, received int, function string, args []string) error {
+	name := transactionName(function)
+	if name == "" {
+		return nil
+	}
+	expected, ok := cc.parameters[name]
+	if !ok || len(args) == expected {
+		return nil
+	}
+
+	var counts []int
+	if direct, ok := cc.parameters[transactionName(called)]; ok {
+		counts = append(counts, direct)
+	}
+	counts = append(counts, legacyArgumentCounts[called]...)
+	sort.Ints(counts)
+	details := map[string]string{"function": called, "received": strconv.Itoa(received)}
+	if len(counts) < 2 {
+		details["expected"] = strconv.Itoa(expected)
+		return newError(CodeInvalidArgument, msgArgumentCount, details)
+	}
+	var first []string
+	for _, count := range counts[:len(counts)-1] {
+		first = append(first, strconv.Itoa(count))
+	}
+	details["expected"] = strings.Join(first, ", ")
+	details["last"] = strconv.Itoa(counts[len(counts)-1])
+	return newError(CodeInvalidArgument, msgArgumentCounts, details)
+}
+
+// Nome do método do contrato para o nome chamado, sem o prefixo do contrato e com a primeira letra maiúscula
+func transactionName(function string) string {
+	name := strings.TrimPrefix(function, contractName+":")
+	if name == "" {
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// Método de inicialização da cadeia.
+// Pode receber como argumento a configuração JSON com as regras de papéis, por exemplo
+// {"roles":{"supplier":{"mspIds":["Org1MSP"]},"wandmaker":{"attribute":"studio.role","value":"wandmaker"},"admin":{"mspIds":["Org0MSP"]}}}
+// e, no mesmo documento, os owners, materiais e receitas iniciais (ver Genesis).
+// O documento pode vir depois do nome da função ({"Args":["init","{...}"]}) ou sozinho ({"Args":["{...}"]}).
+// Sem argumentos os papéis não são verificados. A configuração não é uma transação do contrato,
+// para que ninguém possa trocá-la depois do Init
+func (cc *StudioChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	args := stub.GetStringArgs()
+	transient := transientLanguage(stub)
+	if len(args) > 0 && !strings.HasPrefix(strings.TrimSpace(args[0]), "{") {
+		function := args[0]
+		args = args[1:]
+		// Um nome que não é init sem documento é, em geral, um documento mal escrito: recusar evita um Init sem papéis
+		if len(args) == 0 && function != "" && !strings.EqualFold(function, "init") {
+			return errorResponse(newError(CodeInvalidArgument, msgInitArguments, map[string]string{"function": function}), errorLanguage(stub, transient))
+		}
+	}
+	if len(args) == 0 {
+		return shim.Success(nil)
+	}
+	if len(args) != 1 {
+		return errorResponse(newError(CodeInvalidArgument, msgInitArguments, nil), errorLanguage(stub, transient))
+	}
+
+	genesis, err := parseGenesis(args[0])
+	if err != nil {
+		return errorResponse(err, errorLanguage(stub, transient))
+	}
+	err = loadGenesis(stub, genesis)
+	if err != nil {
+		return errorResponse(err, errorLanguage(stub, transient))
+	}
+
+	return shim.Success(nil)
+}
+
+// O idioma das mensagens vem do campo transiente "language" ou da configuração do Init (ver errorLanguage e logLanguage)
+func (cc *StudioChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	transient := transientLanguage(stub)
+	language := logLanguage(stub, transient)
+	fmt.Println(renderMessage(language, msgLogInvoke, map[string]string{"function": function}))
+	var err error
+	if isJSONRequest(function, args) {
+		function, args, err = cc.schemas.jsonCall(function, args[0])
+		if err != nil {
+			return errorResponse(err, errorLanguage(stub, transient))
+		}
+	}
+	called, received := function, len(args)
+	function, args, err = legacyCall(function, args)
+	if err != nil {
+		return errorResponse(err, errorLanguage(stub, transient))
+	}
+	err = cc.checkArguments(called, received, function, args)
+	if err != nil {
+		return errorResponse(err, errorLanguage(stub, transient))
+	}
+	response := cc.contract.Invoke(&legacyStub{ChaincodeStubInterface: stub, function: function, args: args, language: language})
+	if response.Status < shim.ERRORTHRESHOLD {
+		if function == metadataFunction {
+			return cc.withInfo(response)
+		}
+		return response
+	}
+	return structuredResponse(response, errorLanguage(stub, transient))
+}
+
+// Troca o "info" da metadata, gerado com o título "undefined", pelo Info do chaincode
+func (cc *StudioChaincode) withInfo(response pb.Response) pb.Response {
+	var chaincodeMetadata map[string]json.RawMessage
+	err := json.Unmarshal(response.Payload, &chaincodeMetadata)
+	if err != nil {
+		return response
+	}
+	info, err := json.Marshal(cc.contract.Info)
+	if err != nil {
+		return response
+	}
+	chaincodeMetadata["info"] = info
+	payload, err := json.Marshal(chaincodeMetadata)
+	if err != nil {
+		return response
+	}
+	return shim.Success(payload)
+}
+
+// Quantidades de argumentos das formas antigas traduzidas pelo legacyCall, além da transação de mesmo nome.
+// migrateOwners aceita qualquer quantidade e fica de fora
+var legacyArgumentCounts = map[string][]int{
+	"createWand":   {3},
+	"getMaterials": {1, 2},
+	"getWands":     {1, 2},
+	"getHistory":   {2},
+}
+
+// Traduz as chamadas antigas que não têm uma transação com o mesmo nome e número de argumentos
+func legacyCall(function string, args []string) (string, []string, error) {
+	if function == "createWand" && len(args) == 3 {
+		// createWand ownerID recipeID count
+		return "CreateWandFromRecipe", args, nil
+	} else if function == "getMaterials" && len(args) > 0 {
+		// getMaterials pageSize [bookmark]
+		return "GetMaterialsPage", pageArgs(args), nil
+	} else if function == "getWands" && len(args) > 0 {
+		// getWands pageSize [bookmark]
+		return "GetWandsPage", pageArgs(args), nil
+	} else if function == "getHistory" && len(args) == 2 {
+		// getHistory ownerID descricao
+		return "GetMaterialHistory", args, nil
+	} else if function == "migrateOwners" {
+		// migrateOwners [ownerID...] vira uma lista JSON
+		ownerIDs := args
+		if ownerIDs == nil {
+			ownerIDs = []string{}
+		}
+		ownerIDsBytes, err := json.Marshal(ownerIDs)
+		if err != nil {
+			return "", nil, internalError(msgSerializeFailed, "owners", err)
+		}
+		return "MigrateOwners", []string{string(ownerIDsBytes)}, nil
+	}
+	return function, args, nil
+}
+
+// O bookmark é opcional nas chamadas antigas
+func pageArgs(args []string) []string {
+	if len(args) == 1 {
+		return []string{args[0], ""}
+	}
+	return args
+}
+
+func unknownTransaction(ctx StudioContextInterface) error {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	return newError(CodeInvalidArgument, msgUnknownFunction, map[string]string{"function": function})
+}
+
+// Stub que apresenta ao contractapi a chamada já traduzida pelo legacyCall e leva o idioma
+// da invocação para os logs das transações. Todo o resto é delegado ao stub da peer
+type legacyStub struct {
+	shim.ChaincodeStubInterface
+	function string
+	args     []string
+	language string
+}
+
+func (s *legacyStub) GetFunctionAndParameters() (string, []string) {
+	return s.function, s.args
+}
+
+func (s *legacyStub) GetStringArgs() []string {
+	return append([]string{s.function}, s.args...)
+}
+
+func (s *legacyStub) GetArgs() [][]byte {
+	args := [][]byte{[]byte(s.function)}
+	for _, arg := range s.args {
+		args = append(args, []byte(arg))
+	}
+	return args
+}
@@ -0,0 +1,249 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+func TestInit(t *testing.T) {
+	h := newHarness(t)
+	h.init().ok()
+	h.init(testConfig).ok()
+
+	h.init(testConfig, "extra").failsWith(CodeInvalidArgument, msgInitArguments)
+	h.init(`{"roles":`).failsWith(CodeInvalidArgument, msgConfigInvalid)
+	h.init(`{"roles":{"mago":{"mspIds":["Org1MSP"]}}}`).failsWith(CodeInvalidArgument, msgConfigUnknownRole)
+	h.init(`{"roles":{"admin":{}}}`).failsWith(CodeInvalidArgument, msgConfigRoleWithoutRule)
+	h.init(`{"language":"fr"}`).failsWith(CodeInvalidArgument, msgConfigUnknownLanguage)
+}
+
+// O documento também pode vir no lugar do nome da função, como em --isInit -c '{"Args":["{...}"]}'
+func TestInitDocumentAsFunction(t *testing.T) {
+	ids := newIdentities(t)
+	h := newHarness(t)
+	initWith := func(args ...string) *result {
+		return h.call(func() pb.Response { return h.cc.Init(h.stub) }, args)
+	}
+
+	initWith(testConfig).ok()
+	h.as(ids.bob).invoke("initOwner", "bob").ok()
+	h.invoke("createWand", "bob").failsWith(CodeUnauthorized, msgAccessMissingRole)
+
+	initWith(" "+testConfig, "extra").failsWith(CodeInvalidArgument, msgInitArguments)
+	initWith(`roles`).failsWith(CodeInvalidArgument, msgInitArguments)
+	initWith(`{"roles":`).failsWith(CodeInvalidArgument, msgConfigInvalid)
+	initWith("Init").ok()
+	initWith().ok()
+}
+
+// Sem configuração os papéis não são verificados, mas o dono do owner continua sendo
+func TestWithoutRoles(t *testing.T) {
+	h := newHarness(t)
+	h.init().ok()
+	ids := newIdentities(t)
+
+	h.as(ids.bob).invoke("initOwner", "bob").ok()
+	h.invoke("initMaterial", "ebano", "1", "bob").ok()
+	h.invoke("initMaterial", "rubi", "1", "bob").ok()
+	h.invoke("createWand", "bob").ok()
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "bob").failsWith(CodeUnauthorized, msgAccessNotOwner)
+}
+
+func TestUnknownFunctionAndArguments(t *testing.T) {
+	h, ids := newStudioHarness(t)
+
+	studioErr := h.invoke("fly", "alice").failsWith(CodeInvalidArgument, msgUnknownFunction)
+	if studioErr.Details["function"] != "fly" {
+		t.Fatalf("unexpected details %v", studioErr.Details)
+	}
+	h.invoke("initOwner").failsWith(CodeInvalidArgument, msgArgumentCount)
+	h.invoke("swapMaterials", "alice", "ebano", "1").failsWith(CodeInvalidArgument, msgArgumentCount)
+	h.invoke("createWand").failsWith(CodeInvalidArgument, msgArgumentCounts)
+	h.invoke("transferWand", "alice").failsWith(CodeInvalidArgument, msgArgumentCount)
+
+	// Argumentos a mais também são recusados, sem gastar nada: createWand sem o count não pode virar a varinha dos dois primeiros materiais
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "alice").ok()
+	h.invoke("initMaterial", "rubi", "1", "alice").ok()
+	// O erro cita o nome chamado e todas as formas aceitas, inclusive as antigas
+	studioErr = h.invoke("createWand", "alice", "classica").failsWith(CodeInvalidArgument, msgArgumentCounts)
+	if studioErr.Details["function"] != "createWand" || studioErr.Details["expected"] != "1" || studioErr.Details["last"] != "3" ||
+		studioErr.Details["received"] != "2" {
+		t.Fatalf("unexpected details %v", studioErr.Details)
+	}
+	studioErr = h.invoke("getMaterials", "5", "", "extra").failsWith(CodeInvalidArgument, msgArgumentCounts)
+	if studioErr.Message != "getMaterials espera 0, 1 ou 2 argumentos, recebeu 3" {
+		t.Fatalf("unexpected message %q", studioErr.Message)
+	}
+	studioErr = h.invoke("swapMaterials", "alice", "ebano", "1", "bob", "junk").failsWith(CodeInvalidArgument, msgArgumentCount)
+	if studioErr.Message != "swapMaterials espera 4 argumentos, recebeu 5" {
+		t.Fatalf("unexpected message %q", studioErr.Message)
+	}
+	h.invoke("StudioContract:initOwner", "carol", "junk").failsWith(CodeInvalidArgument, msgArgumentCount)
+	if h.quantity("alice", "ebano") != 1 || h.quantity("alice", "rubi") != 1 {
+		t.Fatalf("rejected calls changed the ledger")
+	}
+	h.invoke("StudioContract:initOwner", "carol").ok()
+}
+
+func TestJSONRequests(t *testing.T) {
+	h, ids := newStudioHarness(t)
+
+	h.as(ids.alice).invoke("initMaterial", `{"material":"ebano","quantity":3,"owner":"alice"}`).ok()
+	h.invoke("swapMaterials", `{"from":"alice","to":"bob","material":"ebano","quantity":1}`).ok()
+	if h.quantity("alice", "ebano") != 2 || h.quantity("bob", "ebano") != 1 {
+		t.Fatalf("JSON requests did not move the materials")
+	}
+	var page MaterialsPage
+	h.invoke("getMaterials", `{"pageSize":1}`).decode(&page)
+	if page.FetchedRecordsCount != 1 {
+		t.Fatalf("unexpected page %+v", page)
+	}
+	var history []HistoryEntry
+	h.invoke("getHistory", `{"owner":"alice","material":"ebano"}`).decode(&history)
+	if len(history) != 2 {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	var migrated []string
+	h.as(ids.admin).invoke("migrateOwners", `{"owners":["alice"]}`).decode(&migrated)
+
+	h.invoke("swapMaterials", `{"from":"alice","to":"bob","material":"ebano"}`).failsWith(CodeInvalidArgument, msgInvalidJSONRequest)
+	h.invoke("swapMaterials", `{"from":"alice","to":"bob","material":"ebano","quantity":0}`).failsWith(CodeInvalidArgument, msgInvalidJSONRequest)
+	h.invoke("initOwner", `{"id":"carol","extra":1}`).failsWith(CodeInvalidArgument, msgInvalidJSONRequest)
+	h.invoke("getHistory", `{"id":"alice","owner":"alice"}`).failsWith(CodeInvalidArgument, msgInvalidJSONRequest)
+	h.invoke("initOwner", `{"id":`).failsWith(CodeInvalidArgument, msgInvalidJSONRequest)
+
+	// O schema "integer" aceita 2.0 e 1e2, mas só literais inteiros chegam à transação
+	h.as(ids.alice).invoke("swapMaterials", `{"from":"alice","to":"bob","material":"ebano","quantity":2.0}`).failsWith(CodeInvalidArgument, msgInvalidJSONRequest)
+	h.invoke("getMaterials", `{"pageSize":1e2}`).failsWith(CodeInvalidArgument, msgInvalidJSONRequest)
+	h.as(ids.admin).invoke("registerRecipe", `{"recipe":"r","materials":[{"descricao":"ebano","quantidade":1.0}]}`).failsWith(CodeInvalidArgument, msgInvalidJSONRequest)
+	if h.quantity("alice", "ebano") != 2 {
+		t.Fatalf("rejected requests changed the ledger")
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	h, _ := newStudioHarness(t)
+
+	portuguese := h.invoke("QueryOwner", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	english := h.withLanguage(languageEnglish).invoke("QueryOwner", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	if portuguese.Message == english.Message || !strings.Contains(english.Message, "nobody") {
+		t.Fatalf("message not translated: %q and %q", portuguese.Message, english.Message)
+	}
+	if english.Message != renderMessage(languageEnglish, msgOwnerNotFound, map[string]string{"owner": "nobody"}) {
+		t.Fatalf("unexpected message %q", english.Message)
+	}
+
+	// Idioma da configuração do canal
+	h = newHarness(t)
+	h.init(`{"language":"en"}`).ok()
+	studioErr := h.invoke("getWand", "nope").failsWith(CodeWandNotFound, msgWandNotFound)
+	if studioErr.Message != renderMessage(languageEnglish, msgWandNotFound, map[string]string{"wand": "nope"}) {
+		t.Fatalf("unexpected message %q", studioErr.Message)
+	}
+}
+
+// Stub que anota as chaves lidas, como o read set da transação
+type readRecorder struct {
+	shim.ChaincodeStubInterface
+	reads map[string]bool
+}
+
+func (s *readRecorder) GetState(key string) ([]byte, error) {
+	s.reads[key] = true
+	return s.ChaincodeStubInterface.GetState(key)
+}
+
+// A configuração só é lida para renderizar erros: nas transações bem-sucedidas que não verificam papéis
+// ela não entra no read set, e um novo Init não as invalida
+func TestLanguageDoesNotReadConfig(t *testing.T) {
+	h := newHarness(t)
+	h.init(`{"language":"en"}`).ok()
+	h.as(newIdentities(t).alice)
+	key, err := configKey(h.stub)
+	if err != nil {
+		t.Fatalf("configKey: %s", err)
+	}
+	invoke := func(args ...string) (*result, *readRecorder) {
+		stub := &readRecorder{ChaincodeStubInterface: h.stub, reads: make(map[string]bool)}
+		return h.call(func() pb.Response { return h.cc.Invoke(stub) }, args), stub
+	}
+
+	r, stub := invoke("initOwner", "alice")
+	r.ok()
+	if stub.reads[key] {
+		t.Fatalf("initOwner read the configuration")
+	}
+	r, stub = invoke("QueryOwner", "nobody")
+	studioErr := r.failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	if !stub.reads[key] || studioErr.Message != renderMessage(languageEnglish, msgOwnerNotFound, map[string]string{"owner": "nobody"}) {
+		t.Fatalf("error not rendered in the configured language: %q", studioErr.Message)
+	}
+}
+
+// Os logs também usam o idioma da configuração. Um processo que ainda não viu a configuração do canal a lê uma vez
+func TestLogLanguage(t *testing.T) {
+	h := newHarness(t)
+	h.init(`{"language":"en"}`).ok()
+	if language := logLanguage(h.stub, ""); language != languageEnglish {
+		t.Fatalf("expected the configured language, got %s", language)
+	}
+	if language := logLanguage(h.stub, languagePortuguese); language != languagePortuguese {
+		t.Fatalf("expected the transient language, got %s", language)
+	}
+
+	configLanguages.Delete(h.stub.GetChannelID())
+	key, err := configKey(h.stub)
+	if err != nil {
+		t.Fatalf("configKey: %s", err)
+	}
+	stub := &readRecorder{ChaincodeStubInterface: h.stub, reads: make(map[string]bool)}
+	if language := logLanguage(stub, ""); language != languageEnglish || !stub.reads[key] {
+		t.Fatalf("expected the configuration to be read, got %s", language)
+	}
+	stub.reads = make(map[string]bool)
+	if language := logLanguage(stub, ""); language != languageEnglish || stub.reads[key] {
+		t.Fatalf("expected the remembered language, got %s", language)
+	}
+
+	h.init(`{"language":"pt-BR"}`).ok()
+	if language := logLanguage(h.stub, ""); language != languagePortuguese {
+		t.Fatalf("expected the language of the new Init, got %s", language)
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	h, _ := newStudioHarness(t)
+
+	var contractMetadata struct {
+		Info struct {
+			Title string `json:"title"`
+		} `json:"info"`
+		Contracts map[string]struct {
+			Transactions []struct {
+				Name string `json:"name"`
+			} `json:"transactions"`
+		} `json:"contracts"`
+	}
+	err := json.Unmarshal(h.invoke("org.hyperledger.fabric:GetMetadata").ok(), &contractMetadata)
+	if err != nil {
+		t.Fatalf("invalid metadata: %s", err)
+	}
+	found := false
+	for _, contract := range contractMetadata.Contracts {
+		for _, transaction := range contract.Transactions {
+			if transaction.Name == "SwapMaterials" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("SwapMaterials missing from the metadata")
+	}
+	if contractMetadata.Info.Title != "Studio" {
+		t.Fatalf("unexpected chaincode title %q", contractMetadata.Info.Title)
+	}
+}
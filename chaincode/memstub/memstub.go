@@ -0,0 +1,193 @@
+// Package memstub hospeda um chaincode em uma ledger em memória, sem peer nem rede.
+// É usado pelos testes do chaincode e pelo simulador cmd/studiosim
+package memstub
+
+import (
+	"container/list"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// O shimtest.MockStub com o que ele não implementa e a peer implementa,
+// a paginação das chaves compostas e o histórico das chaves. As chamadas são entregues direto ao
+// Init/Invoke do chaincode com este stub (ver Call), porque o MockInvoke passaria o MockStub sem os complementos
+type Stub struct {
+	*shimtest.MockStub
+	args    []string
+	history map[string][]*queryresult.KeyModification
+}
+
+func New(name string, cc shim.Chaincode) *Stub {
+	return &Stub{
+		MockStub: shimtest.NewMockStub(name, cc),
+		history:  make(map[string][]*queryresult.KeyModification),
+	}
+}
+
+// Executa run como a transação txID com os argumentos args (o primeiro é o nome da função).
+// Os eventos emitidos ficam em ChaincodeEventsChannel (ver Events)
+func (s *Stub) Call(txID string, args []string, run func() pb.Response) pb.Response {
+	s.args = args
+	s.MockTransactionStart(txID)
+	defer s.MockTransactionEnd(txID)
+	return run()
+}
+
+// Retira e devolve os eventos emitidos desde a última chamada
+func (s *Stub) Events() []*pb.ChaincodeEvent {
+	var events []*pb.ChaincodeEvent
+	for len(s.ChaincodeEventsChannel) > 0 {
+		events = append(events, <-s.ChaincodeEventsChannel)
+	}
+	return events
+}
+
+// Guarda o estado atual da ledger. A função devolvida volta a ledger a esse estado,
+// para descartar as escritas de uma transação que falhou, como a peer faz
+func (s *Stub) Checkpoint() func() {
+	state := make(map[string][]byte, len(s.State))
+	for key, value := range s.State {
+		state[key] = value
+	}
+	keys := list.New()
+	keys.PushBackList(s.Keys)
+	history := make(map[string][]*queryresult.KeyModification, len(s.history))
+	for key, modifications := range s.history {
+		history[key] = modifications
+	}
+	return func() {
+		s.State = state
+		s.Keys = keys
+		s.history = history
+	}
+}
+
+func (s *Stub) GetArgs() [][]byte {
+	args := make([][]byte, len(s.args))
+	for i, arg := range s.args {
+		args[i] = []byte(arg)
+	}
+	return args
+}
+
+func (s *Stub) GetStringArgs() []string {
+	return s.args
+}
+
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	if len(s.args) == 0 {
+		return "", []string{}
+	}
+	return s.args[0], s.args[1:]
+}
+
+func (s *Stub) PutState(key string, value []byte) error {
+	err := s.MockStub.PutState(key, value)
+	if err != nil {
+		return err
+	}
+	s.recordHistory(key, value, false)
+	return nil
+}
+
+func (s *Stub) DelState(key string) error {
+	err := s.MockStub.DelState(key)
+	if err != nil {
+		return err
+	}
+	s.recordHistory(key, nil, true)
+	return nil
+}
+
+// Como na peer, cada transação deixa só a sua última escrita no histórico de uma chave
+func (s *Stub) recordHistory(key string, value []byte, isDelete bool) {
+	timestamp, _ := s.GetTxTimestamp()
+	modification := &queryresult.KeyModification{
+		TxId:      s.GetTxID(),
+		Value:     value,
+		Timestamp: timestamp,
+		IsDelete:  isDelete,
+	}
+	modifications := s.history[key]
+	if len(modifications) > 0 && modifications[len(modifications)-1].TxId == modification.TxId {
+		modifications = modifications[:len(modifications)-1]
+	}
+	s.history[key] = append(modifications, modification)
+}
+
+// O histórico é guardado da versão mais antiga para a mais recente e devolvido ao contrário, como na peer (Fabric v2)
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := s.history[key]
+	newestFirst := make([]*queryresult.KeyModification, len(modifications))
+	for i, modification := range modifications {
+		newestFirst[len(modifications)-1-i] = modification
+	}
+	return &historyIterator{modifications: newestFirst}, nil
+}
+
+// O bookmark é a chave em que a próxima página começa, como na peer
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	resultsIterator, err := s.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resultsIterator.Close()
+
+	page := &kvIterator{}
+	metadata := &pb.QueryResponseMetadata{}
+	for resultsIterator.HasNext() {
+		kv, err := resultsIterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if kv.Key < bookmark {
+			continue
+		}
+		if int32(len(page.kvs)) == pageSize {
+			metadata.Bookmark = kv.Key
+			break
+		}
+		page.kvs = append(page.kvs, kv)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.kvs))
+	return page, metadata, nil
+}
+
+type kvIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (it *kvIterator) HasNext() bool {
+	return len(it.kvs) > 0
+}
+
+func (it *kvIterator) Next() (*queryresult.KV, error) {
+	kv := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return kv, nil
+}
+
+func (it *kvIterator) Close() error {
+	return nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (it *historyIterator) HasNext() bool {
+	return len(it.modifications) > 0
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	modification := it.modifications[0]
+	it.modifications = it.modifications[1:]
+	return modification, nil
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,303 @@
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Idiomas do catálogo. O idioma de cada invocação vem do campo transient "language",
+// senão do campo language da configuração do Init (um por canal), senão é o português
+const (
+	languagePortuguese = "pt-BR"
+	languageEnglish    = "en"
+	defaultLanguage    = languagePortuguese
+	languageTransient  = "language"
+)
+
+// Identificador estável de uma mensagem, devolvido como messageId nos erros
+type messageID string
+
+const (
+	msgInitArguments          messageID = "init.arguments"
+	msgConfigInvalid          messageID = "config.invalid"
+	msgConfigUnknownRole      messageID = "config.unknownRole"
+	msgConfigRoleWithoutRule  messageID = "config.roleWithoutRule"
+	msgConfigUnknownLanguage  messageID = "config.unknownLanguage"
+	msgConfigDuplicate        messageID = "config.duplicate"
+	msgConfigOwnerBinding     messageID = "config.ownerBinding"
+	msgAccessNoRule           messageID = "access.noRule"
+	msgAccessMissingRole      messageID = "access.missingRole"
+	msgAccessUnboundOwner     messageID = "access.unboundOwner"
+	msgAccessNotOwner         messageID = "access.notOwner"
+	msgOwnerNotFound          messageID = "owner.notFound"
+	msgOwnerWrongType         messageID = "owner.wrongType"
+	msgOwnerExists            messageID = "owner.exists"
+	msgOwnerBound             messageID = "owner.bound"
+	msgOwnerLegacy            messageID = "owner.legacy"
+	msgMaterialNotFound       messageID = "material.notFound"
+	msgInsufficientQuantity   messageID = "material.insufficientQuantity"
+	msgWandNotFound           messageID = "wand.notFound"
+	msgWandNotEnoughMaterials messageID = "wand.notEnoughMaterials"
+	msgWandNotOwnedBySender   messageID = "wand.notOwnedBySender"
+	msgRecipeEmpty            messageID = "recipe.empty"
+	msgRecipeDuplicate        messageID = "recipe.duplicateMaterial"
+	msgRecipeExists           messageID = "recipe.exists"
+	msgRecipeNotFound         messageID = "recipe.notFound"
+	msgRecipeRetired          messageID = "recipe.retired"
+	msgPageSize               messageID = "page.size"
+	msgIDEmpty                messageID = "validation.idEmpty"
+	msgIDTooLong              messageID = "validation.idTooLong"
+	msgIDInvalid              messageID = "validation.idInvalid"
+	msgKeyInvalid             messageID = "validation.keyInvalid"
+	msgDescriptionEmpty       messageID = "validation.descriptionEmpty"
+	msgDescriptionTooLong     messageID = "validation.descriptionTooLong"
+	msgDescriptionInvalid     messageID = "validation.descriptionInvalid"
+	msgQuantityNotPositive    messageID = "validation.quantityNotPositive"
+	msgQuantityNegative       messageID = "validation.quantityNegative"
+	msgWandCountTooLarge      messageID = "validation.wandCountTooLarge"
+	msgQuantityOverflow       messageID = "validation.quantityOverflow"
+	msgSelfTransfer           messageID = "validation.selfTransfer"
+	msgUnknownFunction        messageID = "request.unknownFunction"
+	msgInvalidJSONRequest     messageID = "request.invalidJSON"
+	msgInvalidSchema          messageID = "request.invalidSchema"
+	msgContractArguments      messageID = "request.contractArguments"
+	msgArgumentCount          messageID = "request.argumentCount"
+	msgArgumentCounts         messageID = "request.argumentCounts"
+	msgKeyFailed              messageID = "ledger.keyFailed"
+	msgReadFailed             messageID = "ledger.readFailed"
+	msgWriteFailed            messageID = "ledger.writeFailed"
+	msgDeleteFailed           messageID = "ledger.deleteFailed"
+	msgQueryFailed            messageID = "ledger.queryFailed"
+	msgPaginationUnsupported  messageID = "ledger.paginationUnsupported"
+	msgSerializeFailed        messageID = "json.serializeFailed"
+	msgDeserializeFailed      messageID = "json.deserializeFailed"
+	msgEventFailed            messageID = "event.failed"
+	msgIdentityFailed         messageID = "identity.readFailed"
+	msgInternal               messageID = "internal"
+	msgLogInvoke              messageID = "log.invoke"
+	msgLogRichQuery           messageID = "log.richQueryUnavailable"
+)
+
+// Textos de cada mensagem. {nome} é trocado pelo detalhe de mesmo nome do erro
+var catalog = map[string]map[messageID]string{
+	languagePortuguese: {
+		msgInitArguments:          "Argumentos incorretos. Espera-se nenhum ou o documento JSON de configuração e estado inicial, opcionalmente depois do nome da função init",
+		msgConfigInvalid:          "Configuração inválida: {error}",
+		msgConfigUnknownRole:      "Configuração inválida: papel desconhecido {role}. Espera-se \"supplier\", \"wandmaker\" ou \"admin\"",
+		msgConfigRoleWithoutRule:  "Configuração inválida: o papel {role} precisa de mspIds ou attribute",
+		msgConfigUnknownLanguage:  "Configuração inválida: idioma desconhecido {language}. Espera-se \"pt-BR\" ou \"en\"",
+		msgConfigDuplicate:        "Configuração inválida: {kind} repetido: {id}",
+		msgConfigOwnerBinding:     "Configuração inválida: o owner {owner} precisa de mspId e clientId, ou de nenhum dos dois",
+		msgAccessNoRule:           "Acesso negado: nenhuma regra para o papel {role} foi configurada no Init",
+		msgAccessMissingRole:      "Acesso negado: o cliente {caller} não tem o papel {role}",
+		msgAccessUnboundOwner:     "Acesso negado: owner {owner} não está vinculado a nenhuma identidade",
+		msgAccessNotOwner:         "Acesso negado: o cliente {caller} não é o dono do owner {owner}",
+		msgOwnerNotFound:          "Owner não existe: {owner}",
+		msgOwnerWrongType:         "A chave {owner} não guarda um owner (docType \"{docType}\")",
+		msgOwnerExists:            "Este owner já existe: {owner}",
+		msgOwnerBound:             "O owner {owner} já está vinculado a outra identidade",
+		msgOwnerLegacy:            "Owner {owner} ainda guarda itens no formato antigo. Execute migrateOwners antes",
+		msgMaterialNotFound:       "Material {material} não encontrado nos materiais de {owner}",
+		msgInsufficientQuantity:   "Quantidade insuficiente do material {material} do owner {owner}: possui {available}, precisa de {required}",
+		msgWandNotFound:           "Varinha não existe: {wand}",
+		msgWandNotEnoughMaterials: "Owner {owner} não tem materiais suficientes para uma varinha: precisa de 2 tipos de material diferentes",
+		msgWandNotOwnedBySender:   "A varinha {wand} não pertence ao sender {owner}",
+		msgRecipeEmpty:            "A receita precisa de pelo menos 1 material",
+		msgRecipeDuplicate:        "Material {material} repetido na receita",
+		msgRecipeExists:           "Esta receita já existe: {recipe}",
+		msgRecipeNotFound:         "Receita não existe: {recipe}",
+		msgRecipeRetired:          "Receita aposentada: {recipe}",
+		msgPageSize:               "Tamanho da página deve ser um numero inteiro positivo",
+		msgIDEmpty:                "{field} não pode ser vazio",
+		msgIDTooLong:              "{field} deve ter no máximo {max} caracteres",
+		msgIDInvalid:              "{field} inválido: {value}. Use letras, números, \".\", \"_\" ou \"-\", começando por letra ou número",
+		msgKeyInvalid:             "{field} inválido: {value}. Não use o caractere nulo, U+10FFFF nem UTF-8 inválido",
+		msgDescriptionEmpty:       "A descrição do material não pode ser vazia",
+		msgDescriptionTooLong:     "A descrição do material {material} deve ter no máximo {max} caracteres",
+		msgDescriptionInvalid:     "Descrição de material inválida: {material}. Não use caracteres de controle nem espaços no início ou no fim",
+		msgQuantityNotPositive:    "{field} deve ser um inteiro positivo: {value}",
+		msgQuantityNegative:       "{field} não pode ser negativo: {value}",
+		msgWandCountTooLarge:      "count deve ser no máximo {max}: {value}",
+		msgQuantityOverflow:       "A quantidade do material {material} do owner {owner} ultrapassaria o máximo suportado",
+		msgSelfTransfer:           "Sender e recipiente são o mesmo owner: {owner}",
+		msgUnknownFunction:        "Nome de função inválido: {function}. Espera-se \"getMaterials\", \"initOwner\", \"bootstrapOwner\", \"QueryOwner\", \"initMaterial\", \"getWands\", \"swapMaterials\", \"createWand\", \"transferWand\", \"getWand\", \"registerRecipe\", \"updateRecipe\", \"retireRecipe\", \"getRecipe\", \"getMaterialsByDescription\", \"getWandsByOwner\", \"getOwnersWithMaterial\", \"getHistory\", \"migrateOwners\" ou \"consolidateInventory\"",
+		msgInvalidJSONRequest:     "Requisição JSON inválida para {function}: {error}",
+		msgInvalidSchema:          "Schema inválido para {function}: {error}",
+		msgContractArguments:      "Argumentos inválidos: {error}",
+		msgArgumentCount:          "{function} espera {expected} argumentos, recebeu {received}",
+		msgArgumentCounts:         "{function} espera {expected} ou {last} argumentos, recebeu {received}",
+		msgKeyFailed:              "Falha ao criar a chave de {item}: {error}",
+		msgReadFailed:             "Falha ao ler {item}: {error}",
+		msgWriteFailed:            "Falha ao gravar {item}: {error}",
+		msgDeleteFailed:           "Falha ao apagar {item}: {error}",
+		msgQueryFailed:            "Falha ao consultar {item}: {error}",
+		msgPaginationUnsupported:  "Paginação não suportada ao consultar {item}",
+		msgSerializeFailed:        "Falha ao serializar {item}: {error}",
+		msgDeserializeFailed:      "Falha ao deserializar {item}: {error}",
+		msgEventFailed:            "Falha ao registrar o evento {event}: {error}",
+		msgIdentityFailed:         "Falha ao ler {item} do cliente: {error}",
+		msgInternal:               "Erro interno: {error}",
+		msgLogInvoke:              "Invoke é chamado: {function}",
+		msgLogRichQuery:           "Consulta rica indisponível ({error}), varrendo chaves compostas",
+	},
+	languageEnglish: {
+		msgInitArguments:          "Incorrect arguments. Expecting none or the JSON configuration and initial state document, optionally after the function name init",
+		msgConfigInvalid:          "Invalid configuration: {error}",
+		msgConfigUnknownRole:      "Invalid configuration: unknown role {role}. Expecting \"supplier\", \"wandmaker\" or \"admin\"",
+		msgConfigRoleWithoutRule:  "Invalid configuration: role {role} needs mspIds or attribute",
+		msgConfigUnknownLanguage:  "Invalid configuration: unknown language {language}. Expecting \"pt-BR\" or \"en\"",
+		msgConfigDuplicate:        "Invalid configuration: duplicate {kind}: {id}",
+		msgConfigOwnerBinding:     "Invalid configuration: owner {owner} needs both mspId and clientId, or neither",
+		msgAccessNoRule:           "Access denied: no rule for role {role} was configured in Init",
+		msgAccessMissingRole:      "Access denied: client {caller} does not have role {role}",
+		msgAccessUnboundOwner:     "Access denied: owner {owner} is not bound to any identity",
+		msgAccessNotOwner:         "Access denied: client {caller} does not own owner {owner}",
+		msgOwnerNotFound:          "Owner does not exist: {owner}",
+		msgOwnerWrongType:         "Key {owner} does not hold an owner (docType \"{docType}\")",
+		msgOwnerExists:            "This owner already exists: {owner}",
+		msgOwnerBound:             "Owner {owner} is already bound to another identity",
+		msgOwnerLegacy:            "Owner {owner} still holds items in the old layout. Run migrateOwners first",
+		msgMaterialNotFound:       "Material {material} not found in the materials of {owner}",
+		msgInsufficientQuantity:   "Insufficient quantity of material {material} owned by {owner}: has {available}, needs {required}",
+		msgWandNotFound:           "Wand does not exist: {wand}",
+		msgWandNotEnoughMaterials: "Owner {owner} does not have enough materials to create a wand: needs 2 distinct material types",
+		msgWandNotOwnedBySender:   "Wand {wand} is not owned by sender {owner}",
+		msgRecipeEmpty:            "The recipe needs at least 1 material",
+		msgRecipeDuplicate:        "Material {material} repeated in the recipe",
+		msgRecipeExists:           "This recipe already exists: {recipe}",
+		msgRecipeNotFound:         "Recipe does not exist: {recipe}",
+		msgRecipeRetired:          "Recipe retired: {recipe}",
+		msgPageSize:               "Page size must be a positive integer",
+		msgIDEmpty:                "{field} must not be empty",
+		msgIDTooLong:              "{field} must be at most {max} characters long",
+		msgIDInvalid:              "Invalid {field}: {value}. Use letters, digits, \".\", \"_\" or \"-\", starting with a letter or digit",
+		msgKeyInvalid:             "Invalid {field}: {value}. Do not use the null character, U+10FFFF or invalid UTF-8",
+		msgDescriptionEmpty:       "The material description must not be empty",
+		msgDescriptionTooLong:     "The description of material {material} must be at most {max} characters long",
+		msgDescriptionInvalid:     "Invalid material description: {material}. Do not use control characters or leading or trailing spaces",
+		msgQuantityNotPositive:    "{field} must be a positive integer: {value}",
+		msgQuantityNegative:       "{field} must not be negative: {value}",
+		msgWandCountTooLarge:      "count must be at most {max}: {value}",
+		msgQuantityOverflow:       "The quantity of material {material} owned by {owner} would exceed the supported maximum",
+		msgSelfTransfer:           "Sender and receiver are the same owner: {owner}",
+		msgUnknownFunction:        "Invalid invoke function name {function}. Expecting \"getMaterials\", \"initOwner\", \"bootstrapOwner\", \"QueryOwner\", \"initMaterial\", \"getWands\", \"swapMaterials\", \"createWand\", \"transferWand\", \"getWand\", \"registerRecipe\", \"updateRecipe\", \"retireRecipe\", \"getRecipe\", \"getMaterialsByDescription\", \"getWandsByOwner\", \"getOwnersWithMaterial\", \"getHistory\", \"migrateOwners\" or \"consolidateInventory\"",
+		msgInvalidJSONRequest:     "Invalid JSON request for {function}: {error}",
+		msgInvalidSchema:          "Invalid schema for {function}: {error}",
+		msgContractArguments:      "Invalid arguments: {error}",
+		msgArgumentCount:          "{function} expects {expected} arguments, got {received}",
+		msgArgumentCounts:         "{function} expects {expected} or {last} arguments, got {received}",
+		msgKeyFailed:              "Failed to create the key for {item}: {error}",
+		msgReadFailed:             "Failed to read {item}: {error}",
+		msgWriteFailed:            "Failed to save {item}: {error}",
+		msgDeleteFailed:           "Failed to delete {item}: {error}",
+		msgQueryFailed:            "Failed to query {item}: {error}",
+		msgPaginationUnsupported:  "Pagination is not supported when querying {item}",
+		msgSerializeFailed:        "Failed to serialize {item}: {error}",
+		msgDeserializeFailed:      "Failed to deserialize {item}: {error}",
+		msgEventFailed:            "Failed to set event {event}: {error}",
+		msgIdentityFailed:         "Failed to read the client {item}: {error}",
+		msgInternal:               "Internal error: {error}",
+		msgLogInvoke:              "Invoke called: {function}",
+		msgLogRichQuery:           "Rich query unavailable ({error}), scanning composite keys",
+	},
+}
+
+func isSupportedLanguage(language string) bool {
+	_, ok := catalog[language]
+	return ok
+}
+
+// Monta o texto da mensagem no idioma pedido, trocando {nome} pelos detalhes
+func renderMessage(language string, id messageID, details map[string]string) string {
+	messages, ok := catalog[language]
+	if !ok {
+		messages = catalog[defaultLanguage]
+	}
+	text, ok := messages[id]
+	if !ok {
+		return string(id)
+	}
+	var replacements []string
+	for name, value := range details {
+		replacements = append(replacements, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(text)
+}
+
+// Idioma pedido no campo transient "language" da invocação. Vazio se o campo falta ou tem um idioma desconhecido
+func transientLanguage(stub shim.ChaincodeStubInterface) string {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return ""
+	}
+	language := string(transient[languageTransient])
+	if !isSupportedLanguage(language) {
+		return ""
+	}
+	return language
+}
+
+// Idioma de uma mensagem de erro: o do transient, o da configuração do Init ou o padrão.
+// A configuração só é lida aqui, quando um erro é renderizado: lida em toda invocação, a chave da configuração entraria
+// no read set de todas as transações, e um novo Init (no upgrade) invalidaria por MVCC as transações em andamento
+func errorLanguage(stub shim.ChaincodeStubInterface, transient string) string {
+	if transient != "" {
+		return transient
+	}
+	config, err := getConfig(stub)
+	if err != nil {
+		return defaultLanguage
+	}
+	return configLanguage(config)
+}
+
+// Idioma definido na configuração, ou o padrão se não há configuração ou ela não define um idioma
+func configLanguage(config *StudioConfig) string {
+	if config != nil && isSupportedLanguage(config.Language) {
+		return config.Language
+	}
+	return defaultLanguage
+}
+
+// Idioma da configuração de cada canal já visto por este processo, usado só nos logs (ver logLanguage)
+var configLanguages sync.Map
+
+// Anota o idioma da configuração do canal sempre que ela é lida ou gravada
+func rememberConfigLanguage(stub shim.ChaincodeStubInterface, config *StudioConfig) {
+	configLanguages.Store(stub.GetChannelID(), configLanguage(config))
+}
+
+// Idioma dos logs: o do transient ou o da configuração do Init, como nos erros. Diferente dos erros, que vão na resposta
+// e precisam ser iguais em todas as peers, o log não precisa ler a ledger a cada invocação: a configuração é lida
+// uma vez por canal, na primeira invocação que este processo recebe, e depois o idioma é atualizado em cada
+// leitura ou gravação da configuração (verificação de papéis, erros, Init). Assim ela fica fora do read set das
+// outras transações, e um novo Init não as invalida
+func logLanguage(stub shim.ChaincodeStubInterface, transient string) string {
+	if transient != "" {
+		return transient
+	}
+	if language, ok := configLanguages.Load(stub.GetChannelID()); ok {
+		return language.(string)
+	}
+	config, err := getConfig(stub)
+	if err != nil {
+		return defaultLanguage
+	}
+	return configLanguage(config)
+}
+
+// Idioma guardado no stub da invocação pelo StudioChaincode
+func stubLanguage(stub shim.ChaincodeStubInterface) string {
+	if languageStub, ok := stub.(*legacyStub); ok {
+		return languageStub.language
+	}
+	return defaultLanguage
+}
+
+// Escreve uma linha de log no idioma da invocação
+func logMessage(stub shim.ChaincodeStubInterface, id messageID, details map[string]string) {
+	fmt.Println(renderMessage(stubLanguage(stub), id, details))
+}
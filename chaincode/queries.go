@@ -0,0 +1,209 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Consultas ricas para a CouchDB. Os índices usados ficam em META-INF/statedb/couchdb/indexes e são instalados junto com o chaincode.
+// Em peers com LevelDB a GetQueryResult falha e as consultas caem para uma varredura das chaves compostas.
+// Outras falhas da consulta (CouchDB fora do ar, índice inválido) são devolvidas como INTERNAL
+const (
+	materialDescricaoIndexDoc = "_design/indexMaterialDescricaoDoc"
+	materialDescricaoIndex    = "indexMaterialDescricao"
+	wandOwnerIndexDoc         = "_design/indexWandOwnerDoc"
+	wandOwnerIndex            = "indexWandOwner"
+)
+
+// Monta a query da CouchDB serializando o seletor, assim valores vindos dos argumentos não quebram o JSON
+func buildQuery(selector map[string]interface{}, indexDoc string, index string) (string, error) {
+	query := map[string]interface{}{
+		"selector":  selector,
+		"use_index": []string{indexDoc, index},
+	}
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return "", internalError(msgSerializeFailed, "query", err)
+	}
+	return string(queryBytes), nil
+}
+
+// A peer com LevelDB responde "ExecuteQuery not supported for leveldb"; o MockStub, "not implemented"
+func richQueryUnsupported(err error) bool {
+	return strings.Contains(err.Error(), "not supported") || strings.Contains(err.Error(), "not implemented")
+}
+
+// Executa uma consulta rica de materiais. Sem suporte a consultas ricas devolve o erro da peer, para o chamador varrer as chaves
+func richQueryMaterials(stub shim.ChaincodeStubInterface, query string) ([]Material, error) {
+	resultsIterator, err := stub.GetQueryResult(query)
+	if err != nil {
+		if richQueryUnsupported(err) {
+			return nil, err
+		}
+		return nil, internalError(msgQueryFailed, "materials", err)
+	}
+	defer resultsIterator.Close()
+
+	var materials []Material
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError(msgReadFailed, "materials", err)
+		}
+		var material Material
+		err = json.Unmarshal(queryResponse.Value, &material)
+		if err != nil {
+			return nil, internalError(msgDeserializeFailed, "material "+queryResponse.Key, err)
+		}
+		materials = append(materials, material)
+	}
+	return materials, nil
+}
+
+// Executa uma consulta rica de varinhas
+func richQueryWands(stub shim.ChaincodeStubInterface, query string) ([]Wand, error) {
+	resultsIterator, err := stub.GetQueryResult(query)
+	if err != nil {
+		if richQueryUnsupported(err) {
+			return nil, err
+		}
+		return nil, internalError(msgQueryFailed, "wands", err)
+	}
+	defer resultsIterator.Close()
+
+	var wands []Wand
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError(msgReadFailed, "wands", err)
+		}
+		var wand Wand
+		err = json.Unmarshal(queryResponse.Value, &wand)
+		if err != nil {
+			return nil, internalError(msgDeserializeFailed, "wand "+queryResponse.Key, err)
+		}
+		wands = append(wands, wand)
+	}
+	return wands, nil
+}
+
+// Materiais de uma descrição em todos os owners com pelo menos minQuantidade unidades
+func queryMaterialsByDescription(stub shim.ChaincodeStubInterface, descricao string, minQuantidade int) ([]Material, error) {
+	query, err := buildQuery(map[string]interface{}{
+		"docType":    docTypeMaterial,
+		"descricao":  descricao,
+		"quantidade": map[string]interface{}{"$gte": minQuantidade},
+	}, materialDescricaoIndexDoc, materialDescricaoIndex)
+	if err != nil {
+		return nil, err
+	}
+	materials, err := richQueryMaterials(stub, query)
+	if err == nil {
+		return materials, nil
+	}
+	if hasCode(err, CodeInternal) {
+		return nil, err
+	}
+
+	// LevelDB: varre material~owner~descricao de todos os owners
+	logMessage(stub, msgLogRichQuery, map[string]string{"error": err.Error()})
+	all, err := queryMaterials(stub)
+	if err != nil {
+		return nil, err
+	}
+	materials = nil
+	for _, material := range all {
+		if material.Descricao == descricao && material.Quantidade >= minQuantidade {
+			materials = append(materials, material)
+		}
+	}
+	return materials, nil
+}
+
+// Varinhas de um owner
+func queryWandsByOwner(stub shim.ChaincodeStubInterface, ownerID string) ([]Wand, error) {
+	query, err := buildQuery(map[string]interface{}{
+		"docType": docTypeWand,
+		"owner":   ownerID,
+	}, wandOwnerIndexDoc, wandOwnerIndex)
+	if err != nil {
+		return nil, err
+	}
+	wands, err := richQueryWands(stub, query)
+	if err == nil {
+		return wands, nil
+	}
+	if hasCode(err, CodeInternal) {
+		return nil, err
+	}
+
+	// LevelDB: as varinhas do owner já ficam agrupadas em wand~owner~*
+	logMessage(stub, msgLogRichQuery, map[string]string{"error": err.Error()})
+	return queryWands(stub, ownerID)
+}
+
+// Lista os materiais de uma descrição em todos os owners
+// Tem como entrada a descrição do material
+func (c *StudioContract) GetMaterialsByDescription(ctx StudioContextInterface, descricao string) ([]Material, error) {
+	err := validateDescriptionReference(descricao)
+	if err != nil {
+		return nil, err
+	}
+	descricao = canonicalDescription(descricao)
+
+	materials, err := queryMaterialsByDescription(ctx.GetStub(), descricao, 0)
+	if err != nil {
+		return nil, err
+	}
+	if materials == nil {
+		materials = []Material{}
+	}
+	return materials, nil
+}
+
+// Lista as varinhas de um owner
+// Tem como entrada o ID do owner
+func (c *StudioContract) GetWandsByOwner(ctx StudioContextInterface, ownerID string) ([]Wand, error) {
+	err := validateIDReference("owner", ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ctx.Repository().findOwner(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	wands, err := queryWandsByOwner(ctx.GetStub(), ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if wands == nil {
+		wands = []Wand{}
+	}
+	return wands, nil
+}
+
+// Lista os owners que possuem pelo menos N unidades de um material, com a quantidade de cada um
+// Tem como entrada a descrição do material e a quantidade mínima
+func (c *StudioContract) GetOwnersWithMaterial(ctx StudioContextInterface, descricao string, minQuantidade int) ([]Material, error) {
+	err := validate(
+		validateDescriptionReference(descricao),
+		validateMinQuantity("minQuantity", minQuantidade),
+	)
+	if err != nil {
+		return nil, err
+	}
+	descricao = canonicalDescription(descricao)
+
+	materials, err := queryMaterialsByDescription(ctx.GetStub(), descricao, minQuantidade)
+	if err != nil {
+		return nil, err
+	}
+	if materials == nil {
+		materials = []Material{}
+	}
+	return materials, nil
+}
@@ -0,0 +1,90 @@
+package chaincode
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// O MockStub não tem CouchDB, então as consultas passam pelo caminho das chaves compostas
+func TestMaterialQueries(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "3", "alice").ok()
+	h.invoke("initMaterial", "rubi", "1", "alice").ok()
+	h.as(ids.bob).invoke("initMaterial", "ebano", "1", "bob").ok()
+
+	var materials []Material
+	h.invoke("getMaterialsByDescription", "ebano").decode(&materials)
+	if len(materials) != 2 {
+		t.Fatalf("unexpected materials %+v", materials)
+	}
+	h.invoke("getOwnersWithMaterial", "ebano", "2").decode(&materials)
+	if len(materials) != 1 || materials[0].Owner != "alice" || materials[0].Quantidade != 3 {
+		t.Fatalf("unexpected owners %+v", materials)
+	}
+	h.invoke("getMaterialsByDescription", "pena").decode(&materials)
+	if len(materials) != 0 {
+		t.Fatalf("unexpected materials %+v", materials)
+	}
+
+	// Buscas usam a forma canônica e só recusam o que quebra as chaves
+	h.invoke("getMaterialsByDescription", " ebano").decode(&materials)
+	if len(materials) != 2 {
+		t.Fatalf("unexpected materials %+v", materials)
+	}
+	h.invoke("getMaterialsByDescription", "eb\x00ano").failsWith(CodeInvalidArgument, msgKeyInvalid)
+	h.invoke("getMaterialsByDescription", " ").failsWith(CodeInvalidArgument, msgDescriptionEmpty)
+	h.invoke("getOwnersWithMaterial", "ebano", "-1").failsWith(CodeInvalidArgument, msgQuantityNegative)
+	h.invoke("getOwnersWithMaterial", "ebano").failsWith(CodeInvalidArgument, msgArgumentCount)
+}
+
+func TestGetWandsByOwner(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "alice").ok()
+	h.invoke("initMaterial", "rubi", "1", "alice").ok()
+	h.invoke("createWand", "alice").ok()
+
+	var wands []Wand
+	h.invoke("getWandsByOwner", "alice").decode(&wands)
+	if len(wands) != 1 || wands[0].Owner != "alice" {
+		t.Fatalf("unexpected wands %+v", wands)
+	}
+	h.invoke("getWandsByOwner", "bob").decode(&wands)
+	if len(wands) != 0 {
+		t.Fatalf("unexpected wands %+v", wands)
+	}
+
+	h.invoke("getWandsByOwner", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+}
+
+// Stub de uma peer com CouchDB em que a consulta rica falha
+type failingRichQuery struct {
+	shim.ChaincodeStubInterface
+}
+
+func (s *failingRichQuery) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errors.New("couchdb unreachable")
+}
+
+// Só a falta de suporte a consultas ricas cai para a varredura das chaves; outras falhas são INTERNAL
+func TestRichQueryFailure(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "alice").ok()
+	invoke := func(args ...string) *result {
+		stub := &failingRichQuery{ChaincodeStubInterface: h.stub}
+		return h.call(func() pb.Response { return h.cc.Invoke(stub) }, args)
+	}
+
+	for _, args := range [][]string{
+		{"getMaterialsByDescription", "ebano"},
+		{"getOwnersWithMaterial", "ebano", "1"},
+		{"getWandsByOwner", "alice"},
+	} {
+		studioErr := invoke(args...).failsWith(CodeInternal, msgQueryFailed)
+		if studioErr.Details["error"] != "couchdb unreachable" {
+			t.Fatalf("%v: unexpected details %v", args, studioErr.Details)
+		}
+	}
+}
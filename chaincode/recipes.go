@@ -0,0 +1,315 @@
+package chaincode
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// As receitas ficam em recipe~recipeID
+const recipeIndex = "recipe"
+
+// Receita de varinha: quais materiais e quanto de cada um uma varinha consome
+// Receitas aposentadas (Ativa = false) continuam na ledger mas não produzem novas varinhas
+type Recipe struct {
+	ObjectType string           `json:"docType"`
+	Id         string           `json:"id"`
+	Materiais  []RecipeMaterial `json:"materiais"`
+	Ativa      bool             `json:"ativa"`
+}
+
+// Quantidade de um material exigida por uma receita para produzir uma varinha
+type RecipeMaterial struct {
+	Descricao  string `json:"descricao"`
+	Quantidade int    `json:"quantidade"`
+}
+
+func recipeKey(stub shim.ChaincodeStubInterface, recipeID string) (string, error) {
+	return stub.CreateCompositeKey(recipeIndex, []string{recipeID})
+}
+
+// Busca uma receita na ledger. Retorna nil se a receita não existe; as transações usam o repository
+func getRecipe(stub shim.ChaincodeStubInterface, recipeID string) (*Recipe, error) {
+	key, err := recipeKey(stub, recipeID)
+	if err != nil {
+		return nil, internalError(msgKeyFailed, "recipe "+recipeID, err)
+	}
+	recipeBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, internalError(msgReadFailed, "recipe "+recipeID, err)
+	}
+	if recipeBytes == nil {
+		return nil, nil
+	}
+	var recipe Recipe
+	err = json.Unmarshal(recipeBytes, &recipe)
+	if err != nil {
+		return nil, internalError(msgDeserializeFailed, "recipe "+recipeID, err)
+	}
+	return &recipe, nil
+}
+
+func putRecipe(stub shim.ChaincodeStubInterface, recipe *Recipe) error {
+	key, err := recipeKey(stub, recipe.Id)
+	if err != nil {
+		return internalError(msgKeyFailed, "recipe "+recipe.Id, err)
+	}
+	recipeBytes, err := json.Marshal(recipe)
+	if err != nil {
+		return internalError(msgSerializeFailed, "recipe "+recipe.Id, err)
+	}
+	err = stub.PutState(key, recipeBytes)
+	if err != nil {
+		return internalError(msgWriteFailed, "recipe "+recipe.Id, err)
+	}
+	return nil
+}
+
+// Valida a lista de materiais de uma receita, passada como JSON, por exemplo [{"descricao":"ebano","quantidade":1},{"descricao":"rubi","quantidade":2}]
+// Cada material deve aparecer uma única vez (comparando as descrições na forma canônica) e com quantidade positiva
+func validateRecipeMaterials(materials []RecipeMaterial) error {
+	if len(materials) == 0 {
+		return newError(CodeInvalidArgument, msgRecipeEmpty, nil)
+	}
+	seen := make(map[string]bool)
+	for _, material := range materials {
+		err := validate(
+			validateDescriptionReference(material.Descricao),
+			validateQuantity("quantidade", material.Quantidade),
+		)
+		if err != nil {
+			return withDetail(err, "material", material.Descricao)
+		}
+		descricao := canonicalDescription(material.Descricao)
+		if seen[descricao] {
+			return newError(CodeInvalidArgument, msgRecipeDuplicate, map[string]string{"material": material.Descricao})
+		}
+		seen[descricao] = true
+	}
+	return nil
+}
+
+// Receita com as descrições na forma em que os materiais são guardados
+func canonicalRecipeMaterials(materials []RecipeMaterial) []RecipeMaterial {
+	canonical := make([]RecipeMaterial, len(materials))
+	for i, material := range materials {
+		canonical[i] = RecipeMaterial{Descricao: canonicalDescription(material.Descricao), Quantidade: material.Quantidade}
+	}
+	return canonical
+}
+
+// Registra uma nova receita na ledger. Só admins
+// Possui como entrada o ID da receita e a lista de materiais
+func (c *StudioContract) RegisterRecipe(ctx StudioContextInterface, recipeID string, materials []RecipeMaterial) error {
+	err := validate(
+		validateID("recipe", recipeID),
+		validateRecipeMaterials(materials),
+	)
+	if err != nil {
+		return err
+	}
+	materials = canonicalRecipeMaterials(materials)
+
+	err = ctx.AssertRole(roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	stub := ctx.GetStub()
+	exists, err := ctx.Repository().recipeExists(recipeID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return newError(CodeAlreadyExists, msgRecipeExists, map[string]string{"recipe": recipeID})
+	}
+
+	recipe := Recipe{
+		ObjectType: docTypeRecipe,
+		Id:         recipeID,
+		Materiais:  materials,
+		Ativa:      true,
+	}
+	err = putRecipe(stub, &recipe)
+	if err != nil {
+		return err
+	}
+
+	return ctx.EmitEvent(eventRecipeRegistered, StudioEvent{Receita: recipeID})
+}
+
+// Troca os materiais de uma receita existente. Só admins
+// Possui como entrada o ID da receita e a nova lista de materiais
+// Varinhas já produzidas guardam os materiais que consumiram e não mudam
+func (c *StudioContract) UpdateRecipe(ctx StudioContextInterface, recipeID string, materials []RecipeMaterial) error {
+	err := validate(
+		validateIDReference("recipe", recipeID),
+		validateRecipeMaterials(materials),
+	)
+	if err != nil {
+		return err
+	}
+	materials = canonicalRecipeMaterials(materials)
+
+	err = ctx.AssertRole(roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	stub := ctx.GetStub()
+	recipe, err := ctx.Repository().findRecipe(recipeID)
+	if err != nil {
+		return err
+	}
+
+	recipe.Materiais = materials
+	err = putRecipe(stub, recipe)
+	if err != nil {
+		return err
+	}
+
+	return ctx.EmitEvent(eventRecipeUpdated, StudioEvent{Receita: recipeID})
+}
+
+// Aposenta uma receita, que deixa de produzir varinhas. Só admins
+// Possui como entrada o ID da receita
+func (c *StudioContract) RetireRecipe(ctx StudioContextInterface, recipeID string) error {
+	err := validateIDReference("recipe", recipeID)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.AssertRole(roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	stub := ctx.GetStub()
+	recipe, err := ctx.Repository().findRecipe(recipeID)
+	if err != nil {
+		return err
+	}
+
+	recipe.Ativa = false
+	err = putRecipe(stub, recipe)
+	if err != nil {
+		return err
+	}
+
+	return ctx.EmitEvent(eventRecipeRetired, StudioEvent{Receita: recipeID})
+}
+
+// Get recipe pega uma receita pelo seu ID
+// Tem como entrada o ID da receita
+func (c *StudioContract) GetRecipe(ctx StudioContextInterface, recipeID string) (*Recipe, error) {
+	err := validateIDReference("recipe", recipeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.Repository().findRecipe(recipeID)
+}
+
+// Produz varinhas a partir de uma receita
+// Possui como entrada o ID do owner, o ID da receita e quantas varinhas produzir
+// Consome exatamente a quantidade de cada material pedida pela receita, multiplicada pelo número de varinhas
+// Retorna as varinhas criadas
+func (c *StudioContract) CreateWandFromRecipe(ctx StudioContextInterface, ownerID string, recipeID string, count int) ([]Wand, error) {
+	err := validate(
+		validateIDReference("owner", ownerID),
+		validateIDReference("recipe", recipeID),
+		validateWandCount(count),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Só wandmakers produzem varinhas
+	err = ctx.AssertRole(roleWandmaker)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verifica o owner na ledger e se quem chama é o seu dono
+	_, err = ctx.GetOwnerForCaller(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	recipe, err := ctx.Repository().findRecipe(recipeID)
+	if err != nil {
+		return nil, err
+	}
+	if !recipe.Ativa {
+		return nil, newError(CodeRecipeRetired, msgRecipeRetired, map[string]string{"recipe": recipeID})
+	}
+
+	// Consome os materiais da receita. Nada é gravado se faltar algum material
+	inv := ctx.Inventory(ownerID)
+	for _, required := range recipe.Materiais {
+		quantidade, ok := multiplyQuantities(required.Quantidade, count)
+		if !ok {
+			return nil, withDetail(quantityOverflowError(ownerID, required.Descricao), "recipe", recipeID)
+		}
+		err = inv.consume(required.Descricao, quantidade)
+		if err != nil {
+			return nil, withDetail(err, "recipe", recipeID)
+		}
+	}
+	err = inv.save()
+	if err != nil {
+		return nil, err
+	}
+
+	// Cada varinha registra os materiais que consumiu
+	var wands []Wand
+	var wandIDs []string
+	for i := 0; i < count; i++ {
+		var consumed []Material
+		for _, required := range recipe.Materiais {
+			consumed = append(consumed, Material{
+				ObjectType: docTypeMaterial,
+				Descricao:  required.Descricao,
+				Quantidade: required.Quantidade,
+				Owner:      ownerID,
+			})
+		}
+		wand := Wand{
+			ObjectType: docTypeWand,
+			Materiais:  consumed,
+			Quantidade: 1,
+			Owner:      ownerID,
+			Id:         newWandID(stub, i),
+			Receita:    recipeID,
+		}
+		err = putWand(stub, &wand)
+		if err != nil {
+			return nil, err
+		}
+		wands = append(wands, wand)
+		wandIDs = append(wandIDs, wand.Id)
+	}
+
+	// O evento traz o total consumido de cada material
+	var consumed []Material
+	for _, required := range recipe.Materiais {
+		consumed = append(consumed, Material{
+			ObjectType: docTypeMaterial,
+			Descricao:  required.Descricao,
+			Quantidade: required.Quantidade * count,
+			Owner:      ownerID,
+		})
+	}
+	err = ctx.EmitEvent(eventWandCreated, StudioEvent{
+		Owners:    []string{ownerID},
+		Materiais: consumed,
+		Wands:     wandIDs,
+		Receita:   recipeID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wands, nil
+}
@@ -0,0 +1,92 @@
+package chaincode
+
+import (
+	"testing"
+)
+
+const testRecipe = `[{"descricao":"ebano","quantidade":1},{"descricao":"rubi","quantidade":2}]`
+
+func TestRegisterRecipe(t *testing.T) {
+	h, ids := newStudioHarness(t)
+
+	h.as(ids.admin).invoke("registerRecipe", "classica", testRecipe).ok()
+	event := h.lastEvent(eventRecipeRegistered)
+	if event.Receita != "classica" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	var recipe Recipe
+	h.invoke("getRecipe", "classica").decode(&recipe)
+	if !recipe.Ativa || len(recipe.Materiais) != 2 || recipe.Materiais[1].Quantidade != 2 {
+		t.Fatalf("unexpected recipe %+v", recipe)
+	}
+
+	h.invoke("registerRecipe", "classica", testRecipe).failsWith(CodeAlreadyExists, msgRecipeExists)
+	h.invoke("registerRecipe", "vazia", `[]`).failsWith(CodeInvalidArgument, msgRecipeEmpty)
+	h.invoke("registerRecipe", "repetida", `[{"descricao":"ebano","quantidade":1},{"descricao":"ebano","quantidade":2}]`).failsWith(CodeInvalidArgument, msgRecipeDuplicate)
+	h.invoke("registerRecipe", "zero", `[{"descricao":"ebano","quantidade":0}]`).failsWith(CodeInvalidArgument, msgQuantityNotPositive)
+	h.invoke("registerRecipe", "quebrada", `[{`).failsWith(CodeInvalidArgument, msgContractArguments)
+	h.as(ids.alice).invoke("registerRecipe", "outra", testRecipe).failsWith(CodeUnauthorized, msgAccessMissingRole)
+}
+
+func TestUpdateAndRetireRecipe(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.admin).invoke("registerRecipe", "classica", testRecipe).ok()
+
+	h.invoke("updateRecipe", "classica", `[{"descricao":"pena","quantidade":3}]`).ok()
+	h.lastEvent(eventRecipeUpdated)
+	var recipe Recipe
+	h.invoke("getRecipe", "classica").decode(&recipe)
+	if len(recipe.Materiais) != 1 || recipe.Materiais[0].Descricao != "pena" {
+		t.Fatalf("recipe not updated: %+v", recipe)
+	}
+
+	h.invoke("retireRecipe", "classica").ok()
+	h.lastEvent(eventRecipeRetired)
+	h.invoke("getRecipe", "classica").decode(&recipe)
+	if recipe.Ativa {
+		t.Fatalf("recipe not retired: %+v", recipe)
+	}
+
+	h.invoke("updateRecipe", "nope", testRecipe).failsWith(CodeRecipeNotFound, msgRecipeNotFound)
+	h.invoke("retireRecipe", "nope").failsWith(CodeRecipeNotFound, msgRecipeNotFound)
+	h.invoke("getRecipe", "nope").failsWith(CodeRecipeNotFound, msgRecipeNotFound)
+	h.as(ids.bob).invoke("retireRecipe", "classica").failsWith(CodeUnauthorized, msgAccessMissingRole)
+}
+
+func TestCreateWandFromRecipe(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.admin).invoke("registerRecipe", "classica", testRecipe).ok()
+	h.as(ids.alice).invoke("initMaterial", "ebano", "3", "alice").ok()
+	h.invoke("initMaterial", "rubi", "5", "alice").ok()
+
+	var wands []Wand
+	h.invoke("createWand", "alice", "classica", "2").decode(&wands)
+	if len(wands) != 2 || wands[0].Id == wands[1].Id || wands[0].Receita != "classica" {
+		t.Fatalf("unexpected wands %+v", wands)
+	}
+	event := h.lastEvent(eventWandCreated)
+	if len(event.Wands) != 2 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if h.quantity("alice", "ebano") != 1 || h.quantity("alice", "rubi") != 1 {
+		t.Fatalf("expected 1 ebano and 1 rubi left, got %d and %d", h.quantity("alice", "ebano"), h.quantity("alice", "rubi"))
+	}
+
+	// Falta rubi para mais uma varinha, e nada é consumido
+	studioErr := h.invoke("createWand", "alice", "classica", "1").failsWith(CodeInsufficientQuantity, msgInsufficientQuantity)
+	if studioErr.Details["recipe"] != "classica" {
+		t.Fatalf("expected the recipe in the details, got %v", studioErr.Details)
+	}
+	if h.quantity("alice", "ebano") != 1 {
+		t.Fatalf("materials were consumed by a failed transaction")
+	}
+
+	h.invoke("createWand", "alice", "nope", "1").failsWith(CodeRecipeNotFound, msgRecipeNotFound)
+	h.invoke("createWand", "alice", "classica", "0").failsWith(CodeInvalidArgument, msgQuantityNotPositive)
+	h.invoke("createWand", "alice", "classica", "101").failsWith(CodeInvalidArgument, msgWandCountTooLarge)
+	h.invoke("createWand", `{"owner":"alice","recipe":"classica","count":101}`).failsWith(CodeInvalidArgument, msgInvalidJSONRequest)
+	h.as(ids.admin).invoke("retireRecipe", "classica").ok()
+	h.as(ids.alice).invoke("createWand", "alice", "classica", "1").failsWith(CodeRecipeRetired, msgRecipeRetired)
+	h.as(ids.bob).invoke("createWand", "bob", "classica", "1").failsWith(CodeUnauthorized, msgAccessMissingRole)
+}
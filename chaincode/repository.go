@@ -0,0 +1,84 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Acesso das transações aos owners, varinhas e receitas da ledger.
+// Os métodos find devolvem o documento ou um erro de não encontrado com o código do tipo do documento
+// (OWNER_NOT_FOUND, WAND_NOT_FOUND, RECIPE_NOT_FOUND), nunca um documento nil.
+// Quem só precisa saber se o documento existe usa os métodos exists
+type repository struct {
+	stub shim.ChaincodeStubInterface
+}
+
+func newRepository(stub shim.ChaincodeStubInterface) *repository {
+	return &repository{stub: stub}
+}
+
+func (repo *repository) findOwner(ownerID string) (*Owner, error) {
+	owner, err := getOwner(repo.stub, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, newError(CodeOwnerNotFound, msgOwnerNotFound, map[string]string{"owner": ownerID})
+	}
+	return owner, nil
+}
+
+// Carrega um owner que será alterado pela transação.
+// Retorna erro se o owner não existe ou se ainda não foi migrado para as chaves compostas
+func (repo *repository) findOwnerForUpdate(ownerID string) (*Owner, error) {
+	owner, err := repo.findOwner(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if isLegacyOwner(owner) {
+		return nil, newError(CodeMigrationRequired, msgOwnerLegacy, map[string]string{"owner": ownerID})
+	}
+	return owner, nil
+}
+
+// Uma chave que guarda outro tipo de documento não é um owner, mas também não está livre: conta como existente,
+// para que quem cria owners responda ALREADY_EXISTS em vez de sobrescrever o documento
+func (repo *repository) ownerExists(ownerID string) (bool, error) {
+	owner, err := getOwner(repo.stub, ownerID)
+	if hasCode(err, CodeOwnerNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return owner != nil, nil
+}
+
+func (repo *repository) findWand(wandID string) (*Wand, error) {
+	wand, err := getWand(repo.stub, wandID)
+	if err != nil {
+		return nil, err
+	}
+	if wand == nil {
+		return nil, newError(CodeWandNotFound, msgWandNotFound, map[string]string{"wand": wandID})
+	}
+	return wand, nil
+}
+
+func (repo *repository) findRecipe(recipeID string) (*Recipe, error) {
+	recipe, err := getRecipe(repo.stub, recipeID)
+	if err != nil {
+		return nil, err
+	}
+	if recipe == nil {
+		return nil, newError(CodeRecipeNotFound, msgRecipeNotFound, map[string]string{"recipe": recipeID})
+	}
+	return recipe, nil
+}
+
+func (repo *repository) recipeExists(recipeID string) (bool, error) {
+	recipe, err := getRecipe(repo.stub, recipeID)
+	if err != nil {
+		return false, err
+	}
+	return recipe != nil, nil
+}
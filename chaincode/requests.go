@@ -0,0 +1,218 @@
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Forma JSON das funções: em vez dos argumentos posicionais a função recebe um único objeto JSON,
+// por exemplo swapMaterials {"from":"alice","to":"bob","material":"ebano","quantity":2}.
+// O objeto é validado contra o schema da função e depois convertido para a forma posicional,
+// na ordem de fields. Campos opcionais ausentes são omitidos, o que escolhe a forma antiga com menos argumentos
+type requestSpec struct {
+	fields []string
+	schema string
+	// Transação chamada diretamente, quando a forma posicional não comporta o campo (lista de owners do migrateOwners)
+	function string
+}
+
+const (
+	idSchema       = `{"type":"string","minLength":1}`
+	quantitySchema = `{"type":"integer","minimum":1}`
+	recipeSchema   = `{"type":"array","minItems":1,"items":{"type":"object","properties":{"descricao":` + idSchema + `,"quantidade":` + quantitySchema + `},"required":["descricao","quantidade"],"additionalProperties":false}}`
+)
+
+// O mesmo limite de validateWandCount
+var wandCountSchema = `{"type":"integer","minimum":1,"maximum":` + strconv.Itoa(maxWandCount) + `}`
+
+var requestSpecs = map[string]requestSpec{
+	"initOwner": {
+		fields: []string{"id"},
+		schema: `{"type":"object","properties":{"id":` + idSchema + `},"required":["id"],"additionalProperties":false}`,
+	},
+	"bootstrapOwner": {
+		fields: []string{"owner", "mspId", "clientId"},
+		schema: `{"type":"object","properties":{"owner":` + idSchema + `,"mspId":` + idSchema + `,"clientId":` + idSchema + `},"required":["owner","mspId","clientId"],"additionalProperties":false}`,
+	},
+	"QueryOwner": {
+		fields: []string{"owner"},
+		schema: `{"type":"object","properties":{"owner":` + idSchema + `},"required":["owner"],"additionalProperties":false}`,
+	},
+	"initMaterial": {
+		fields: []string{"material", "quantity", "owner"},
+		schema: `{"type":"object","properties":{"material":` + idSchema + `,"quantity":` + quantitySchema + `,"owner":` + idSchema + `},"required":["material","quantity","owner"],"additionalProperties":false}`,
+	},
+	"swapMaterials": {
+		fields: []string{"from", "material", "quantity", "to"},
+		schema: `{"type":"object","properties":{"from":` + idSchema + `,"to":` + idSchema + `,"material":` + idSchema + `,"quantity":` + quantitySchema + `},"required":["from","to","material","quantity"],"additionalProperties":false}`,
+	},
+	"getMaterials": {
+		fields: []string{"pageSize", "bookmark"},
+		schema: `{"type":"object","properties":{"pageSize":` + quantitySchema + `,"bookmark":{"type":"string"}},"dependencies":{"bookmark":["pageSize"]},"additionalProperties":false}`,
+	},
+	"getWands": {
+		fields: []string{"pageSize", "bookmark"},
+		schema: `{"type":"object","properties":{"pageSize":` + quantitySchema + `,"bookmark":{"type":"string"}},"dependencies":{"bookmark":["pageSize"]},"additionalProperties":false}`,
+	},
+	"getWand": {
+		fields: []string{"wand"},
+		schema: `{"type":"object","properties":{"wand":` + idSchema + `},"required":["wand"],"additionalProperties":false}`,
+	},
+	"createWand": {
+		fields: []string{"owner", "recipe", "count"},
+		schema: `{"type":"object","properties":{"owner":` + idSchema + `,"recipe":` + idSchema + `,"count":` + wandCountSchema + `},"required":["owner"],"dependencies":{"recipe":["count"],"count":["recipe"]},"additionalProperties":false}`,
+	},
+	"transferWand": {
+		fields: []string{"from", "wand", "to"},
+		schema: `{"type":"object","properties":{"from":` + idSchema + `,"wand":` + idSchema + `,"to":` + idSchema + `},"required":["from","wand","to"],"additionalProperties":false}`,
+	},
+	"registerRecipe": {
+		fields: []string{"recipe", "materials"},
+		schema: `{"type":"object","properties":{"recipe":` + idSchema + `,"materials":` + recipeSchema + `},"required":["recipe","materials"],"additionalProperties":false}`,
+	},
+	"updateRecipe": {
+		fields: []string{"recipe", "materials"},
+		schema: `{"type":"object","properties":{"recipe":` + idSchema + `,"materials":` + recipeSchema + `},"required":["recipe","materials"],"additionalProperties":false}`,
+	},
+	"retireRecipe": {
+		fields: []string{"recipe"},
+		schema: `{"type":"object","properties":{"recipe":` + idSchema + `},"required":["recipe"],"additionalProperties":false}`,
+	},
+	"getRecipe": {
+		fields: []string{"recipe"},
+		schema: `{"type":"object","properties":{"recipe":` + idSchema + `},"required":["recipe"],"additionalProperties":false}`,
+	},
+	"getMaterialsByDescription": {
+		fields: []string{"material"},
+		schema: `{"type":"object","properties":{"material":` + idSchema + `},"required":["material"],"additionalProperties":false}`,
+	},
+	"getWandsByOwner": {
+		fields: []string{"owner"},
+		schema: `{"type":"object","properties":{"owner":` + idSchema + `},"required":["owner"],"additionalProperties":false}`,
+	},
+	"getOwnersWithMaterial": {
+		fields: []string{"material", "minQuantity"},
+		schema: `{"type":"object","properties":{"material":` + idSchema + `,"minQuantity":{"type":"integer","minimum":0}},"required":["material","minQuantity"],"additionalProperties":false}`,
+	},
+	"getHistory": {
+		fields: []string{"id", "owner", "material"},
+		schema: `{"type":"object","properties":{"id":` + idSchema + `,"owner":` + idSchema + `,"material":` + idSchema + `},"oneOf":[{"required":["id"],"not":{"anyOf":[{"required":["owner"]},{"required":["material"]}]}},{"required":["owner","material"],"not":{"required":["id"]}}],"additionalProperties":false}`,
+	},
+	"consolidateInventory": {
+		fields: []string{"owner"},
+		schema: `{"type":"object","properties":{"owner":` + idSchema + `},"required":["owner"],"additionalProperties":false}`,
+	},
+	"migrateOwners": {
+		fields:   []string{"owners"},
+		schema:   `{"type":"object","properties":{"owners":{"type":"array","items":` + idSchema + `}},"required":["owners"],"additionalProperties":false}`,
+		function: "MigrateOwners",
+	},
+}
+
+// Schemas compilados, por nome de função
+type requestSchemas map[string]*gojsonschema.Schema
+
+func compileRequestSchemas() (requestSchemas, error) {
+	schemas := make(requestSchemas)
+	for function, spec := range requestSpecs {
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(spec.schema))
+		if err != nil {
+			return nil, newError(CodeInternal, msgInvalidSchema, map[string]string{"function": function, "error": err.Error()})
+		}
+		schemas[function] = schema
+	}
+	return schemas, nil
+}
+
+// Uma chamada está na forma JSON quando recebe um único argumento que começa com "{"
+func isJSONRequest(function string, args []string) bool {
+	_, ok := requestSpecs[function]
+	return ok && len(args) == 1 && strings.HasPrefix(strings.TrimSpace(args[0]), "{")
+}
+
+// Valida a forma JSON de uma chamada e a converte para a forma posicional
+func (schemas requestSchemas) jsonCall(function string, request string) (string, []string, error) {
+	spec := requestSpecs[function]
+
+	result, err := schemas[function].Validate(gojsonschema.NewStringLoader(request))
+	if err != nil {
+		return "", nil, newError(CodeInvalidArgument, msgInvalidJSONRequest, map[string]string{"function": function, "error": err.Error()})
+	}
+	if !result.Valid() {
+		var problems []string
+		for _, problem := range result.Errors() {
+			problems = append(problems, problem.String())
+		}
+		return "", nil, newError(CodeInvalidArgument, msgInvalidJSONRequest, map[string]string{"function": function, "error": strings.Join(problems, "; ")})
+	}
+
+	// UseNumber mantém os inteiros como foram escritos, sem passar por float64
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(request)))
+	decoder.UseNumber()
+	err = decoder.Decode(&fields)
+	if err != nil {
+		return "", nil, newError(CodeInvalidArgument, msgInvalidJSONRequest, map[string]string{"function": function, "error": err.Error()})
+	}
+
+	var args []string
+	for _, field := range spec.fields {
+		value, ok := fields[field]
+		if !ok {
+			continue
+		}
+		err = checkIntegers(value)
+		if err != nil {
+			return "", nil, newError(CodeInvalidArgument, msgInvalidJSONRequest, map[string]string{"function": function, "error": field + ": " + err.Error()})
+		}
+		switch value := value.(type) {
+		case string:
+			args = append(args, value)
+		case json.Number:
+			args = append(args, value.String())
+		default:
+			valueBytes, err := json.Marshal(value)
+			if err != nil {
+				return "", nil, internalError(msgSerializeFailed, "field "+field, err)
+			}
+			args = append(args, string(valueBytes))
+		}
+	}
+
+	if spec.function != "" {
+		function = spec.function
+	}
+	return function, args, nil
+}
+
+// Os números dos pedidos são todos inteiros, mas o "integer" do schema também aceita 2.0 e 1e2, que o contractapi
+// não converte para int. Só literais inteiros passam, também dentro de listas e objetos (materiais de uma receita)
+func checkIntegers(value interface{}) error {
+	switch value := value.(type) {
+	case json.Number:
+		_, err := value.Int64()
+		if err != nil {
+			return fmt.Errorf("%s is not an integer literal", value)
+		}
+	case []interface{}:
+		for _, item := range value {
+			err := checkIntegers(item)
+			if err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range value {
+			err := checkIntegers(item)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package chaincode
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Variáveis de ambiente do modo servidor (chaincode-as-a-service). Os nomes são os dos exemplos do Fabric
+const (
+	envChaincodeID     = "CHAINCODE_ID"
+	envServerAddress   = "CHAINCODE_SERVER_ADDRESS"
+	envTLSKey          = "CHAINCODE_TLS_KEY"
+	envTLSCert         = "CHAINCODE_TLS_CERT"
+	envTLSClientCACert = "CHAINCODE_CLIENT_CA_CERT"
+)
+
+// Monta o servidor do chaincode a partir do ambiente (getenv é o os.Getenv). Sem CHAINCODE_SERVER_ADDRESS retorna nil,
+// e o chaincode roda no modo clássico, lançado pela peer.
+// Com o endereço, CHAINCODE_ID (o package ID instalado na peer) é obrigatório. TLS é opcional: a chave e o certificado
+// do servidor são lidos dos arquivos em CHAINCODE_TLS_KEY e CHAINCODE_TLS_CERT, e com CHAINCODE_CLIENT_CA_CERT
+// a peer também precisa apresentar um certificado assinado por essa CA
+func NewChaincodeServer(getenv func(string) string, cc shim.Chaincode) (*shim.ChaincodeServer, error) {
+	address := getenv(envServerAddress)
+	if address == "" {
+		return nil, nil
+	}
+	ccid := getenv(envChaincodeID)
+	if ccid == "" {
+		return nil, fmt.Errorf("%s is required when %s is set", envChaincodeID, envServerAddress)
+	}
+
+	tlsProps := shim.TLSProperties{Disabled: true}
+	keyPath, certPath, clientCAPath := getenv(envTLSKey), getenv(envTLSCert), getenv(envTLSClientCACert)
+	if keyPath != "" || certPath != "" {
+		if keyPath == "" || certPath == "" {
+			return nil, fmt.Errorf("TLS needs both %s and %s", envTLSKey, envTLSCert)
+		}
+		key, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %s", envTLSKey, err)
+		}
+		cert, err := os.ReadFile(certPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %s", envTLSCert, err)
+		}
+		tlsProps = shim.TLSProperties{Key: key, Cert: cert}
+		if clientCAPath != "" {
+			tlsProps.ClientCACerts, err = os.ReadFile(clientCAPath)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %s", envTLSClientCACert, err)
+			}
+		}
+	} else if clientCAPath != "" {
+		return nil, fmt.Errorf("%s needs TLS (%s and %s)", envTLSClientCACert, envTLSKey, envTLSCert)
+	}
+
+	return &shim.ChaincodeServer{
+		CCID:     ccid,
+		Address:  address,
+		CC:       cc,
+		TLSProps: tlsProps,
+	}, nil
+}
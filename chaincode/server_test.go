@@ -0,0 +1,74 @@
+package chaincode
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func environment(env map[string]string) func(string) string {
+	return func(name string) string { return env[name] }
+}
+
+func TestChaincodeServerClassicMode(t *testing.T) {
+	server, err := NewChaincodeServer(environment(map[string]string{envChaincodeID: "studio:abc"}), nil)
+	if err != nil || server != nil {
+		t.Fatalf("expected the classic mode without %s, got %v, %v", envServerAddress, server, err)
+	}
+}
+
+func TestChaincodeServerWithoutTLS(t *testing.T) {
+	server, err := NewChaincodeServer(environment(map[string]string{
+		envChaincodeID:   "studio:abc",
+		envServerAddress: "0.0.0.0:9999",
+	}), nil)
+	if err != nil {
+		t.Fatalf("chaincodeServer: %s", err)
+	}
+	if server.CCID != "studio:abc" || server.Address != "0.0.0.0:9999" || !server.TLSProps.Disabled {
+		t.Fatalf("unexpected server %+v", server)
+	}
+}
+
+func TestChaincodeServerWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"key.pem": "key", "cert.pem": "cert", "ca.pem": "ca"}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatalf("WriteFile: %s", err)
+		}
+	}
+	server, err := NewChaincodeServer(environment(map[string]string{
+		envChaincodeID:     "studio:abc",
+		envServerAddress:   "0.0.0.0:9999",
+		envTLSKey:          filepath.Join(dir, "key.pem"),
+		envTLSCert:         filepath.Join(dir, "cert.pem"),
+		envTLSClientCACert: filepath.Join(dir, "ca.pem"),
+	}), nil)
+	if err != nil {
+		t.Fatalf("chaincodeServer: %s", err)
+	}
+	tls := server.TLSProps
+	if tls.Disabled || string(tls.Key) != "key" || string(tls.Cert) != "cert" || string(tls.ClientCACerts) != "ca" {
+		t.Fatalf("unexpected TLS properties %+v", tls)
+	}
+}
+
+func TestChaincodeServerErrors(t *testing.T) {
+	for _, test := range []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{envServerAddress: ":9999"}, envChaincodeID},
+		{map[string]string{envServerAddress: ":9999", envChaincodeID: "studio:abc", envTLSKey: "key.pem"}, envTLSCert},
+		{map[string]string{envServerAddress: ":9999", envChaincodeID: "studio:abc", envTLSClientCACert: "ca.pem"}, envTLSClientCACert},
+		{map[string]string{envServerAddress: ":9999", envChaincodeID: "studio:abc", envTLSKey: "missing.pem", envTLSCert: "missing.pem"}, "reading"},
+	} {
+		_, err := NewChaincodeServer(environment(test.env), nil)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("%v: expected an error about %s, got %v", test.env, test.expected, err)
+		}
+	}
+}
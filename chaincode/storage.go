@@ -0,0 +1,385 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Materiais e varinhas ficam em chaves compostas próprias, fora do documento do Owner
+// (material~owner~descricao e wand~owner~wandID). Assim duas transações sobre itens diferentes do mesmo owner não disputam a mesma chave no MVCC.
+// O índice wandId~wandID guarda o owner atual da varinha. A chave não muda nas transferências,
+// então permite achar a varinha só pelo seu ID e seu histórico mostra todos os owners por onde ela passou.
+const (
+	materialIndex = "material"
+	wandIndex     = "wand"
+	wandIDIndex   = "wandId"
+)
+
+// Toda chave composta começa com este byte
+const compositeKeyNamespace = "\x00"
+
+func materialKey(stub shim.ChaincodeStubInterface, ownerID string, descricao string) (string, error) {
+	return stub.CreateCompositeKey(materialIndex, []string{ownerID, descricao})
+}
+
+func wandKey(stub shim.ChaincodeStubInterface, ownerID string, wandID string) (string, error) {
+	return stub.CreateCompositeKey(wandIndex, []string{ownerID, wandID})
+}
+
+func wandIDKey(stub shim.ChaincodeStubInterface, wandID string) (string, error) {
+	return stub.CreateCompositeKey(wandIDIndex, []string{wandID})
+}
+
+// Gera o ID da i-ésima varinha criada pela transação.
+// Depende só do TxID, então todos os peers endossantes chegam ao mesmo ID
+func newWandID(stub shim.ChaincodeStubInterface, index int) string {
+	return fmt.Sprintf("%s-%d", stub.GetTxID(), index)
+}
+
+// Busca um owner na ledger. Retorna nil se o owner não existe; as transações usam o repository
+func getOwner(stub shim.ChaincodeStubInterface, ownerID string) (*Owner, error) {
+	ownerAsBytes, err := stub.GetState(ownerID)
+	if err != nil {
+		return nil, internalError(msgReadFailed, "owner "+ownerID, err)
+	}
+	if ownerAsBytes == nil {
+		return nil, nil
+	}
+	if docType := documentType(ownerAsBytes); docType != docTypeOwner {
+		return nil, newError(CodeOwnerNotFound, msgOwnerWrongType, map[string]string{"owner": ownerID, "docType": docType})
+	}
+	var owner Owner
+	err = json.Unmarshal(ownerAsBytes, &owner)
+	if err != nil {
+		return nil, internalError(msgDeserializeFailed, "owner "+ownerID, err)
+	}
+	return &owner, nil
+}
+
+func putOwner(stub shim.ChaincodeStubInterface, owner *Owner) error {
+	ownerBytes, err := json.Marshal(owner)
+	if err != nil {
+		return internalError(msgSerializeFailed, "owner "+owner.Id, err)
+	}
+	err = stub.PutState(owner.Id, ownerBytes)
+	if err != nil {
+		return internalError(msgWriteFailed, "owner "+owner.Id, err)
+	}
+	return nil
+}
+
+// Um owner legado ainda guarda materiais ou varinhas dentro do próprio documento
+func isLegacyOwner(owner *Owner) bool {
+	return len(owner.Materiais) > 0 || len(owner.Wands) > 0
+}
+
+// Busca um material de um owner. Retorna nil se o owner não possui o material
+func getMaterial(stub shim.ChaincodeStubInterface, ownerID string, descricao string) (*Material, error) {
+	key, err := materialKey(stub, ownerID, descricao)
+	if err != nil {
+		return nil, internalError(msgKeyFailed, "material "+descricao, err)
+	}
+	materialBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, internalError(msgReadFailed, "material "+descricao, err)
+	}
+	if materialBytes == nil {
+		return nil, nil
+	}
+	var material Material
+	err = json.Unmarshal(materialBytes, &material)
+	if err != nil {
+		return nil, internalError(msgDeserializeFailed, "material "+descricao, err)
+	}
+	return &material, nil
+}
+
+func putMaterial(stub shim.ChaincodeStubInterface, material *Material) error {
+	key, err := materialKey(stub, material.Owner, material.Descricao)
+	if err != nil {
+		return internalError(msgKeyFailed, "material "+material.Descricao, err)
+	}
+	materialBytes, err := json.Marshal(material)
+	if err != nil {
+		return internalError(msgSerializeFailed, "material "+material.Descricao, err)
+	}
+	err = stub.PutState(key, materialBytes)
+	if err != nil {
+		return internalError(msgWriteFailed, "material "+material.Descricao, err)
+	}
+	return nil
+}
+
+func deleteMaterial(stub shim.ChaincodeStubInterface, material *Material) error {
+	key, err := materialKey(stub, material.Owner, material.Descricao)
+	if err != nil {
+		return internalError(msgKeyFailed, "material "+material.Descricao, err)
+	}
+	err = stub.DelState(key)
+	if err != nil {
+		return internalError(msgDeleteFailed, "material "+material.Descricao, err)
+	}
+	return nil
+}
+
+// Salva a varinha sob wand~owner~wandID e registra seu owner no índice wandId~wandID
+func putWand(stub shim.ChaincodeStubInterface, wand *Wand) error {
+	key, err := wandKey(stub, wand.Owner, wand.Id)
+	if err != nil {
+		return internalError(msgKeyFailed, "wand "+wand.Id, err)
+	}
+	wandBytes, err := json.Marshal(wand)
+	if err != nil {
+		return internalError(msgSerializeFailed, "wand "+wand.Id, err)
+	}
+	err = stub.PutState(key, wandBytes)
+	if err != nil {
+		return internalError(msgWriteFailed, "wand "+wand.Id, err)
+	}
+
+	indexKey, err := wandIDKey(stub, wand.Id)
+	if err != nil {
+		return internalError(msgKeyFailed, "wand index "+wand.Id, err)
+	}
+	err = stub.PutState(indexKey, []byte(wand.Owner))
+	if err != nil {
+		return internalError(msgWriteFailed, "wand index "+wand.Id, err)
+	}
+	return nil
+}
+
+// Remove a varinha da chave do seu owner atual e do índice wandId~wandID
+func deleteWand(stub shim.ChaincodeStubInterface, wand *Wand) error {
+	key, err := wandKey(stub, wand.Owner, wand.Id)
+	if err != nil {
+		return internalError(msgKeyFailed, "wand "+wand.Id, err)
+	}
+	err = stub.DelState(key)
+	if err != nil {
+		return internalError(msgDeleteFailed, "wand "+wand.Id, err)
+	}
+
+	indexKey, err := wandIDKey(stub, wand.Id)
+	if err != nil {
+		return internalError(msgKeyFailed, "wand index "+wand.Id, err)
+	}
+	err = stub.DelState(indexKey)
+	if err != nil {
+		return internalError(msgDeleteFailed, "wand index "+wand.Id, err)
+	}
+	return nil
+}
+
+// Busca uma varinha pelo seu ID. Retorna nil se a varinha não existe; as transações usam o repository
+func getWand(stub shim.ChaincodeStubInterface, wandID string) (*Wand, error) {
+	indexKey, err := wandIDKey(stub, wandID)
+	if err != nil {
+		return nil, internalError(msgKeyFailed, "wand index "+wandID, err)
+	}
+	ownerBytes, err := stub.GetState(indexKey)
+	if err != nil {
+		return nil, internalError(msgReadFailed, "wand "+wandID, err)
+	}
+	if ownerBytes == nil {
+		return nil, nil
+	}
+	ownerID := string(ownerBytes)
+
+	key, err := wandKey(stub, ownerID, wandID)
+	if err != nil {
+		return nil, internalError(msgKeyFailed, "wand "+wandID, err)
+	}
+	wandBytes, err := stub.GetState(key)
+	if err != nil {
+		return nil, internalError(msgReadFailed, "wand "+wandID, err)
+	}
+	if wandBytes == nil {
+		return nil, nil
+	}
+	var wand Wand
+	err = json.Unmarshal(wandBytes, &wand)
+	if err != nil {
+		return nil, internalError(msgDeserializeFailed, "wand "+wandID, err)
+	}
+	return &wand, nil
+}
+
+// Lista os materiais guardados sob material~owner~*. Com attributes vazio lista os materiais de todos os owners
+func queryMaterials(stub shim.ChaincodeStubInterface, attributes ...string) ([]Material, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(materialIndex, attributes)
+	if err != nil {
+		return nil, internalError(msgQueryFailed, "materials", err)
+	}
+	defer resultsIterator.Close()
+
+	var materials []Material
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError(msgReadFailed, "materials", err)
+		}
+		if documentType(queryResponse.Value) != docTypeMaterial {
+			continue
+		}
+		var material Material
+		err = json.Unmarshal(queryResponse.Value, &material)
+		if err != nil {
+			return nil, internalError(msgDeserializeFailed, "material "+queryResponse.Key, err)
+		}
+		materials = append(materials, material)
+	}
+	return materials, nil
+}
+
+// Lista as varinhas guardadas sob wand~owner~*. Com attributes vazio lista as varinhas de todos os owners
+func queryWands(stub shim.ChaincodeStubInterface, attributes ...string) ([]Wand, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(wandIndex, attributes)
+	if err != nil {
+		return nil, internalError(msgQueryFailed, "wands", err)
+	}
+	defer resultsIterator.Close()
+
+	var wands []Wand
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError(msgReadFailed, "wands", err)
+		}
+		if documentType(queryResponse.Value) != docTypeWand {
+			continue
+		}
+		var wand Wand
+		err = json.Unmarshal(queryResponse.Value, &wand)
+		if err != nil {
+			return nil, internalError(msgDeserializeFailed, "wand "+queryResponse.Key, err)
+		}
+		wands = append(wands, wand)
+	}
+	return wands, nil
+}
+
+// Página de materiais devolvida pelo getMaterials paginado.
+// Bookmark é passado na próxima chamada para continuar de onde esta parou
+type MaterialsPage struct {
+	Records             []Material `json:"records"`
+	FetchedRecordsCount int32      `json:"fetchedRecordsCount"`
+	Bookmark            string     `json:"bookmark"`
+}
+
+// Página de varinhas devolvida pelo getWands paginado
+type WandsPage struct {
+	Records             []Wand `json:"records"`
+	FetchedRecordsCount int32  `json:"fetchedRecordsCount"`
+	Bookmark            string `json:"bookmark"`
+}
+
+// Lê uma página dos materiais de todos os owners
+func queryMaterialsPage(stub shim.ChaincodeStubInterface, pageSize int32, bookmark string) (*MaterialsPage, error) {
+	resultsIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(materialIndex, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, internalError(msgQueryFailed, "materials", err)
+	}
+	if resultsIterator == nil {
+		return nil, newError(CodeInternal, msgPaginationUnsupported, map[string]string{"item": "materials"})
+	}
+	defer resultsIterator.Close()
+
+	page := MaterialsPage{Records: []Material{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError(msgReadFailed, "materials", err)
+		}
+		if documentType(queryResponse.Value) != docTypeMaterial {
+			continue
+		}
+		var material Material
+		err = json.Unmarshal(queryResponse.Value, &material)
+		if err != nil {
+			return nil, internalError(msgDeserializeFailed, "material "+queryResponse.Key, err)
+		}
+		page.Records = append(page.Records, material)
+	}
+	if metadata != nil {
+		page.FetchedRecordsCount = metadata.FetchedRecordsCount
+		page.Bookmark = metadata.Bookmark
+	}
+	return &page, nil
+}
+
+// Lê uma página das varinhas de todos os owners
+func queryWandsPage(stub shim.ChaincodeStubInterface, pageSize int32, bookmark string) (*WandsPage, error) {
+	resultsIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(wandIndex, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, internalError(msgQueryFailed, "wands", err)
+	}
+	if resultsIterator == nil {
+		return nil, newError(CodeInternal, msgPaginationUnsupported, map[string]string{"item": "wands"})
+	}
+	defer resultsIterator.Close()
+
+	page := WandsPage{Records: []Wand{}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, internalError(msgReadFailed, "wands", err)
+		}
+		if documentType(queryResponse.Value) != docTypeWand {
+			continue
+		}
+		var wand Wand
+		err = json.Unmarshal(queryResponse.Value, &wand)
+		if err != nil {
+			return nil, internalError(msgDeserializeFailed, "wand "+queryResponse.Key, err)
+		}
+		page.Records = append(page.Records, wand)
+	}
+	if metadata != nil {
+		page.FetchedRecordsCount = metadata.FetchedRecordsCount
+		page.Bookmark = metadata.Bookmark
+	}
+	return &page, nil
+}
+
+// Move os materiais e varinhas guardados dentro de um Owner legado para as chaves compostas.
+// Materiais repetidos com a mesma descrição canônica são somados em uma única entrada (ver mergeMaterials).
+// As varinhas recebem IDs a partir de firstWandIndex, para não colidir com as de outros owners migrados
+// na mesma transação. Retorna quantas varinhas foram migradas
+func migrateOwner(stub shim.ChaincodeStubInterface, owner *Owner, firstWandIndex int) (int, error) {
+	materials, err := mergeMaterials(owner.Id, owner.Materiais)
+	if err != nil {
+		return 0, err
+	}
+	for i := range materials {
+		err = putMaterial(stub, &materials[i])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Varinhas antigas não tinham ID, recebem um ID da transação de migração
+	migratedWands := len(owner.Wands)
+	for i := range owner.Wands {
+		wand := owner.Wands[i]
+		wand.ObjectType = docTypeWand
+		wand.Owner = owner.Id
+		wand.Id = newWandID(stub, firstWandIndex+i)
+		if wand.Materiais == nil {
+			wand.Materiais = []Material{}
+		}
+		err := putWand(stub, &wand)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	owner.Materiais = nil
+	owner.Wands = nil
+	err = putOwner(stub, owner)
+	if err != nil {
+		return 0, err
+	}
+	return migratedWands, nil
+}
@@ -0,0 +1,229 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+
+	"main/chaincode/memstub"
+)
+
+// Os testes não precisam de uma rede Fabric: o harness chama o StudioChaincode sobre a ledger em memória de chaincode/memstub,
+// que completa o shimtest.MockStub com o histórico das chaves e as consultas paginadas por chave composta.
+// Os certificados das identidades, com os MSPs e atributos dos papéis, são gerados na hora (ver newIdentities)
+
+// Chaincode e stub de um teste, com a identidade de quem submete as transações
+type harness struct {
+	t     *testing.T
+	cc    *StudioChaincode
+	stub  *memstub.Stub
+	txs   int
+	event *pb.ChaincodeEvent
+}
+
+// O chaincode não guarda estado entre invocações (o estado fica no stub; só o idioma dos logs é lembrado, ver logLanguage),
+// então todos os testes usam a mesma instância, que é cara de criar: o contractapi gera a metadata por reflexão
+// e os schemas JSON são compilados
+var testChaincode struct {
+	once sync.Once
+	cc   *StudioChaincode
+	err  error
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	testChaincode.once.Do(func() {
+		testChaincode.cc, testChaincode.err = NewStudioChaincode()
+	})
+	cc, err := testChaincode.cc, testChaincode.err
+	if err != nil {
+		t.Fatalf("NewStudioChaincode: %s", err)
+	}
+	stub := memstub.New("studio", cc)
+	return &harness{t: t, cc: cc, stub: stub}
+}
+
+// Harness com a configuração de papéis usada pela maioria dos testes:
+// suppliers são do Org1MSP, wandmakers têm o atributo studio.role=wandmaker e admins são do Org0MSP
+func newConfiguredHarness(t *testing.T) *harness {
+	t.Helper()
+	h := newHarness(t)
+	h.init(testConfig).ok()
+	return h
+}
+
+const testConfig = `{"roles":{"supplier":{"mspIds":["Org1MSP"]},"wandmaker":{"attribute":"studio.role","value":"wandmaker"},"admin":{"mspIds":["Org0MSP"]}}}`
+
+func (h *harness) as(identity []byte) *harness {
+	h.stub.Creator = identity
+	return h
+}
+
+func (h *harness) withLanguage(language string) *harness {
+	h.stub.TransientMap = map[string][]byte{languageTransient: []byte(language)}
+	return h
+}
+
+func (h *harness) init(args ...string) *result {
+	return h.call(func() pb.Response { return h.cc.Init(h.stub) }, append([]string{"init"}, args...))
+}
+
+func (h *harness) invoke(args ...string) *result {
+	return h.call(func() pb.Response { return h.cc.Invoke(h.stub) }, args)
+}
+
+// Cada chamada é uma transação com TxID próprio. O evento da transação, se houver, fica em h.event
+func (h *harness) call(run func() pb.Response, args []string) *result {
+	h.txs++
+	response := h.stub.Call("tx"+strconv.Itoa(h.txs), args, run)
+
+	h.event = nil
+	if events := h.stub.Events(); len(events) > 0 {
+		h.event = events[len(events)-1]
+	}
+	return &result{t: h.t, args: args, response: response}
+}
+
+// Grava um documento direto na ledger, como uma versão antiga do chaincode faria
+func (h *harness) putRaw(key string, value string) {
+	h.t.Helper()
+	h.stub.MockTransactionStart("raw")
+	err := h.stub.MockStub.PutState(key, []byte(value))
+	h.stub.MockTransactionEnd("raw")
+	if err != nil {
+		h.t.Fatalf("PutState %q: %s", key, err)
+	}
+}
+
+func (h *harness) compositeKey(objectType string, attributes ...string) string {
+	h.t.Helper()
+	key, err := h.stub.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		h.t.Fatalf("CreateCompositeKey: %s", err)
+	}
+	return key
+}
+
+// Evento da última transação, deserializado
+func (h *harness) lastEvent(eventType string) StudioEvent {
+	h.t.Helper()
+	if h.event == nil {
+		h.t.Fatalf("expected event %s, got none", eventType)
+	}
+	if h.event.EventName != eventType {
+		h.t.Fatalf("expected event %s, got %s", eventType, h.event.EventName)
+	}
+	var event StudioEvent
+	err := json.Unmarshal(h.event.Payload, &event)
+	if err != nil {
+		h.t.Fatalf("invalid event payload %s: %s", h.event.Payload, err)
+	}
+	return event
+}
+
+// Quantidade de um material de um owner, lida direto da ledger
+func (h *harness) quantity(ownerID string, descricao string) int {
+	h.t.Helper()
+	materialBytes := h.stub.State[h.compositeKey(materialIndex, ownerID, descricao)]
+	if materialBytes == nil {
+		return 0
+	}
+	var material Material
+	err := json.Unmarshal(materialBytes, &material)
+	if err != nil {
+		h.t.Fatalf("invalid material %s: %s", materialBytes, err)
+	}
+	return material.Quantidade
+}
+
+// Resposta de uma chamada
+type result struct {
+	t        *testing.T
+	args     []string
+	response pb.Response
+}
+
+func (r *result) ok() []byte {
+	r.t.Helper()
+	if r.response.Status != shim.OK {
+		r.t.Fatalf("%q: expected status 200, got %d: %s", r.args, r.response.Status, r.response.Message)
+	}
+	return r.response.Payload
+}
+
+// Deserializa o payload de uma resposta de sucesso em value
+func (r *result) decode(value interface{}) {
+	r.t.Helper()
+	payload := r.ok()
+	err := json.Unmarshal(payload, value)
+	if err != nil {
+		r.t.Fatalf("%q: invalid payload %s: %s", r.args, payload, err)
+	}
+}
+
+// Verifica que a chamada falhou com o código pedido e o status correspondente
+func (r *result) fails(code ErrorCode) *StudioError {
+	r.t.Helper()
+	var studioErr StudioError
+	err := json.Unmarshal([]byte(r.response.Message), &studioErr)
+	if err != nil {
+		r.t.Fatalf("%q: expected a JSON error, got %d: %s", r.args, r.response.Status, r.response.Message)
+	}
+	if studioErr.Code != code {
+		r.t.Fatalf("%q: expected %s, got %s: %s", r.args, code, studioErr.Code, studioErr.Message)
+	}
+	if r.response.Status != errorStatus[code] || studioErr.Status != errorStatus[code] {
+		r.t.Fatalf("%q: expected status %d, got %d", r.args, errorStatus[code], r.response.Status)
+	}
+	return &studioErr
+}
+
+// Verifica também o messageId do erro
+func (r *result) failsWith(code ErrorCode, id messageID) *StudioError {
+	r.t.Helper()
+	studioErr := r.fails(code)
+	if studioErr.MessageID != id {
+		r.t.Fatalf("%q: expected message %s, got %s: %s", r.args, id, studioErr.MessageID, studioErr.Message)
+	}
+	return studioErr
+}
+
+func newIdentity(t testing.TB, mspID string, commonName string, attributes map[string]string) []byte {
+	t.Helper()
+	identity, err := memstub.NewIdentity(mspID, commonName, attributes)
+	if err != nil {
+		t.Fatalf("NewIdentity %s: %s", commonName, err)
+	}
+	return identity
+}
+
+// Identidades usadas nos testes
+type identities struct {
+	alice []byte // supplier e wandmaker
+	bob   []byte // supplier
+	admin []byte
+}
+
+func newIdentities(t testing.TB) identities {
+	return identities{
+		alice: newIdentity(t, "Org1MSP", "alice", map[string]string{"studio.role": "wandmaker"}),
+		bob:   newIdentity(t, "Org1MSP", "bob", nil),
+		admin: newIdentity(t, "Org0MSP", "admin", nil),
+	}
+}
+
+// Descrições dos materiais de uma lista, ordenadas
+func descriptions(materials []Material) string {
+	var names []string
+	for _, material := range materials {
+		names = append(names, material.Descricao+"="+strconv.Itoa(material.Quantidade))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
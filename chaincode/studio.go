@@ -0,0 +1,567 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define o holder dos objetos Material e Wand. ID é unico
+// MSPID e ClientID guardam a identidade que criou o owner; só ela pode alterar seus itens
+type Owner struct {
+	ObjectType string     `json:"docType"`
+	Materiais  []Material `json:"materiais"`
+	Wands      []Wand     `json:"wands"`
+	Id         string     `json:"id"`
+	MSPID      string     `json:"mspId"`
+	ClientID   string     `json:"clientId"`
+}
+
+// Objeto generico representante de matéria prima. Atrelado a 1 owner
+type Material struct {
+	ObjectType string `json:"docType"`
+	Descricao  string `json:"descricao"`
+	Quantidade int    `json:"quantidade"`
+	Owner      string `json:"owner"`
+}
+
+// Objeto refinado a partir de pelo menos 2 matérias primas. Atrelado a 1 owner
+// O ID é gerado a partir do TxID da transação que criou a varinha (ver newWandID)
+// Receita é o ID da receita usada, vazia para varinhas feitas pelo createWand de 1 argumento
+type Wand struct {
+	ObjectType string     `json:"docType"`
+	Materiais  []Material `json:"materiais"`
+	Quantidade int        `json:"quantidade"`
+	Owner      string     `json:"owner"`
+	Id         string     `json:"id"`
+	Receita    string     `json:"receita"`
+}
+
+// Contrato Studio para transferencia de materiais e produção de varinhas
+// Cada método exportado é uma transação; o nome chamado pelos clientes é o nome do método com a
+// primeira letra minúscula (initOwner, swapMaterials, ...), ver StudioChaincode para as formas antigas
+type StudioContract struct {
+	contractapi.Contract
+}
+
+// Transações que só leem a ledger, marcadas como evaluate na metadata do contrato
+func (c *StudioContract) GetEvaluateTransactions() []string {
+	return []string{
+		"QueryOwner", "GetMaterials", "GetMaterialsPage", "GetWands", "GetWandsPage", "GetWand", "GetRecipe",
+		"GetMaterialsByDescription", "GetWandsByOwner", "GetOwnersWithMaterial", "GetHistory", "GetMaterialHistory",
+	}
+}
+
+// Cria um novo material na ledger
+// Possui como entrada a descrição do material, sua quantidade e o ID do seu owner
+// O material fica em sua própria chave (material~owner~descricao); se o owner já possui o material a quantidade é somada
+// na pilha existente, comparando as descrições na forma canônica
+func (c *StudioContract) InitMaterial(ctx StudioContextInterface, descricao string, quantidade int, ownerID string) error {
+	err := validate(
+		validateDescription(descricao),
+		validateQuantity("quantity", quantidade),
+		validateIDReference("owner", ownerID),
+	)
+	if err != nil {
+		return err
+	}
+	descricao = canonicalDescription(descricao)
+
+	// Só suppliers cunham matéria prima
+	err = ctx.AssertRole(roleSupplier)
+	if err != nil {
+		return err
+	}
+
+	// Verifica o owner na ledger e se quem chama é o seu dono
+	_, err = ctx.GetOwnerForCaller(ownerID)
+	if err != nil {
+		return err
+	}
+
+	inv := ctx.Inventory(ownerID)
+	err = inv.add(descricao, quantidade)
+	if err != nil {
+		return err
+	}
+	err = inv.save()
+	if err != nil {
+		return err
+	}
+
+	return ctx.EmitEvent(eventMaterialMinted, StudioEvent{
+		Owners:    []string{ownerID},
+		Materiais: []Material{{ObjectType: docTypeMaterial, Descricao: descricao, Quantidade: quantidade, Owner: ownerID}},
+	})
+}
+
+// Init owner inicializa um novo Owner na ledger. Deve usar um ID único
+// Possui como entrada um ID(string)
+// O owner fica vinculado ao MSP ID e ao certificado de quem submeteu a transação
+func (c *StudioContract) InitOwner(ctx StudioContextInterface, ownerID string) error {
+	err := validateID("id", ownerID)
+	if err != nil {
+		return err
+	}
+
+	exists, err := ctx.Repository().ownerExists(ownerID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return newError(CodeAlreadyExists, msgOwnerExists, map[string]string{"owner": ownerID})
+	}
+
+	caller, err := ctx.GetCaller()
+	if err != nil {
+		return err
+	}
+
+	owner := Owner{
+		ObjectType: docTypeOwner,
+		Id:         ownerID,
+		MSPID:      caller.MSPID,
+		ClientID:   caller.ID,
+	}
+	err = putOwner(ctx.GetStub(), &owner)
+	if err != nil {
+		return err
+	}
+
+	return ctx.EmitEvent(eventOwnerCreated, StudioEvent{Owners: []string{ownerID}})
+}
+
+// Bootstrap owner permite a um admin criar um owner já vinculado a outra identidade,
+// ou vincular um owner existente que ainda não tem identidade (por exemplo owners criados antes do vínculo).
+// Um owner já vinculado não pode ser tomado por outra identidade
+// Possui como entrada o ID do owner, o MSP ID e o ID do cliente (formato do cid: base64 de "x509::<subject>::<issuer>")
+// O formato dos IDs só é exigido de owners novos; owners antigos com qualquer ID podem ser revinculados
+func (c *StudioContract) BootstrapOwner(ctx StudioContextInterface, ownerID string, mspID string, clientID string) error {
+	err := validate(
+		validateIDReference("owner", ownerID),
+		validateID("mspId", mspID),
+		validateNotEmpty("clientId", clientID),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.AssertRole(roleAdmin)
+	if err != nil {
+		return err
+	}
+
+	repo := ctx.Repository()
+	exists, err := repo.ownerExists(ownerID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = validateID("owner", ownerID)
+		if err != nil {
+			return err
+		}
+	}
+	eventType := eventOwnerCreated
+	owner := &Owner{
+		ObjectType: docTypeOwner,
+		Id:         ownerID,
+	}
+	if exists {
+		eventType = eventOwnerBound
+		owner, err = repo.findOwner(ownerID)
+		// A chave guarda outro tipo de documento, que não pode virar um owner
+		if hasCode(err, CodeOwnerNotFound) {
+			return newError(CodeAlreadyExists, msgOwnerExists, map[string]string{"owner": ownerID})
+		}
+		if err != nil {
+			return err
+		}
+		if owner.MSPID != "" || owner.ClientID != "" {
+			return newError(CodeAlreadyExists, msgOwnerBound, map[string]string{"owner": ownerID})
+		}
+	}
+	owner.MSPID = mspID
+	owner.ClientID = clientID
+
+	err = putOwner(ctx.GetStub(), owner)
+	if err != nil {
+		return err
+	}
+
+	return ctx.EmitEvent(eventType, StudioEvent{Owners: []string{ownerID}})
+}
+
+// Query owner pega todas dados de um owner na ledger.
+// Tem como entrada o ID do owner
+// Os materiais e varinhas são lidos das chaves compostas do owner. Owners que não existem devolvem OWNER_NOT_FOUND
+func (c *StudioContract) QueryOwner(ctx StudioContextInterface, ownerID string) (*Owner, error) {
+	err := validateIDReference("owner", ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := ctx.Repository().findOwner(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	// Owners legados ainda carregam os itens no próprio documento
+	if !isLegacyOwner(owner) {
+		owner.Materiais, err = queryMaterials(stub, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		owner.Wands, err = queryWands(stub, ownerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// A metadata do contrato não aceita null no lugar das listas
+	if owner.Materiais == nil {
+		owner.Materiais = []Material{}
+	}
+	if owner.Wands == nil {
+		owner.Wands = []Wand{}
+	}
+	for i := range owner.Wands {
+		if owner.Wands[i].Materiais == nil {
+			owner.Wands[i].Materiais = []Material{}
+		}
+	}
+
+	return owner, nil
+}
+
+// Get wands pega todas as varinhas disponiveis na ledger de uma vez
+func (c *StudioContract) GetWands(ctx StudioContextInterface) ([]Wand, error) {
+	wands, err := queryWands(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	if wands == nil {
+		wands = []Wand{}
+	}
+	return wands, nil
+}
+
+// Get wands page pega uma página das varinhas da ledger
+// Tem como entrada o tamanho da página e o bookmark da página anterior (vazio na primeira página)
+func (c *StudioContract) GetWandsPage(ctx StudioContextInterface, pageSize int32, bookmark string) (*WandsPage, error) {
+	err := validatePageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return queryWandsPage(ctx.GetStub(), pageSize, bookmark)
+}
+
+// Get materials pega todos materias disponiveis na ledger de uma vez
+func (c *StudioContract) GetMaterials(ctx StudioContextInterface) ([]Material, error) {
+	materials, err := queryMaterials(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	if materials == nil {
+		materials = []Material{}
+	}
+	return materials, nil
+}
+
+// Get materials page pega uma página dos materiais da ledger
+// Tem como entrada o tamanho da página e o bookmark da página anterior (vazio na primeira página)
+func (c *StudioContract) GetMaterialsPage(ctx StudioContextInterface, pageSize int32, bookmark string) (*MaterialsPage, error) {
+	err := validatePageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return queryMaterialsPage(ctx.GetStub(), pageSize, bookmark)
+}
+
+// Get wand pega uma varinha pelo seu ID
+// Tem como entrada o ID da varinha
+func (c *StudioContract) GetWand(ctx StudioContextInterface, wandID string) (*Wand, error) {
+	err := validateIDReference("wand", wandID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.Repository().findWand(wandID)
+}
+
+// Gera uma nova varinha e registra ela a um owner
+// O método pede o id de um owner, verifica os materiais associados ao ID dele e combina 2 materiais diferentes
+// Tem como entrada o ID do owner e retorna a varinha criada, com seu ID
+// Consome 1 unidade de cada um dos 2 primeiros tipos de material em estoque
+// (para consumir quantidades diferentes use o CreateWandFromRecipe)
+func (c *StudioContract) CreateWand(ctx StudioContextInterface, ownerID string) (*Wand, error) {
+	err := validateIDReference("owner", ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Só wandmakers produzem varinhas
+	err = ctx.AssertRole(roleWandmaker)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verifica o owner na ledger e se quem chama é o seu dono
+	_, err = ctx.GetOwnerForCaller(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	materials, err := queryMaterials(stub, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Escolhe os 2 primeiros tipos diferentes de material que ainda têm estoque
+	var used []Material
+	for _, material := range materials {
+		if material.Quantidade <= 0 {
+			continue
+		}
+		if len(used) == 1 && used[0].Descricao == material.Descricao {
+			continue
+		}
+		used = append(used, Material{
+			ObjectType: docTypeMaterial,
+			Descricao:  material.Descricao,
+			Quantidade: 1,
+			Owner:      ownerID,
+		})
+		if len(used) == 2 {
+			break
+		}
+	}
+
+	// Verifica se o owner tem pelo menos 2 tipos de materiais
+	if len(used) < 2 {
+		return nil, newError(CodeInsufficientQuantity, msgWandNotEnoughMaterials, map[string]string{"owner": ownerID})
+	}
+
+	// Consome 1 unidade de cada material
+	inv := ctx.Inventory(ownerID)
+	for _, material := range used {
+		err = inv.consume(material.Descricao, material.Quantidade)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = inv.save()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a new wand with the consumed materials
+	newWand := Wand{
+		ObjectType: docTypeWand,
+		Materiais:  used,
+		Quantidade: 1,
+		Owner:      ownerID,
+		Id:         newWandID(stub, 0),
+	}
+
+	// Save the new wand under its own key
+	err = putWand(stub, &newWand)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.EmitEvent(eventWandCreated, StudioEvent{
+		Owners:    []string{ownerID},
+		Materiais: newWand.Materiais,
+		Wands:     []string{newWand.Id},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &newWand, nil
+}
+
+// Swap materials permite a troca de materiais entre 2 orgs
+// Possui como entrada de argumentos: Id do enviador, descrição do material a ser enviado, quantidade e ID do recipiente
+func (c *StudioContract) SwapMaterials(ctx StudioContextInterface, senderID string, materialDescription string, quantity int, receiverID string) error {
+	// Quantidades negativas moveriam o estoque do recipiente para o sender
+	err := validate(
+		validateIDReference("from", senderID),
+		validateDescriptionReference(materialDescription),
+		validateQuantity("quantity", quantity),
+		validateIDReference("to", receiverID),
+		validateDistinctOwners(senderID, receiverID),
+	)
+	if err != nil {
+		return err
+	}
+	materialDescription = canonicalDescription(materialDescription)
+
+	// Verifica sender e recipiente na ledger. Só o dono do sender pode enviar
+	_, err = ctx.GetOwnerForCaller(senderID)
+	if err != nil {
+		return withDetail(err, "party", "sender")
+	}
+	_, err = ctx.Repository().findOwnerForUpdate(receiverID)
+	if err != nil {
+		return withDetail(err, "party", "receiver")
+	}
+
+	// Retira o material do sender e soma no recipiente
+	// Se o recipiente ainda não possui o material, a entrada é criada
+	senderInventory := ctx.Inventory(senderID)
+	available, err := senderInventory.quantity(materialDescription)
+	if err != nil {
+		return err
+	}
+	if available == 0 {
+		return newError(CodeMaterialNotFound, msgMaterialNotFound, map[string]string{"owner": senderID, "material": materialDescription})
+	}
+	err = senderInventory.consume(materialDescription, quantity)
+	if err != nil {
+		return err
+	}
+	receiverInventory := ctx.Inventory(receiverID)
+	err = receiverInventory.add(materialDescription, quantity)
+	if err != nil {
+		return err
+	}
+
+	// Salva o material do sender e do recipiente
+	err = senderInventory.save()
+	if err != nil {
+		return err
+	}
+	err = receiverInventory.save()
+	if err != nil {
+		return err
+	}
+
+	return ctx.EmitEvent(eventMaterialTransferred, StudioEvent{
+		Owners:    []string{senderID, receiverID},
+		From:      senderID,
+		To:        receiverID,
+		Materiais: []Material{{ObjectType: docTypeMaterial, Descricao: materialDescription, Quantidade: quantity, Owner: receiverID}},
+	})
+}
+
+// Transfer wand vende uma varinha de um owner para outro
+// Possui como entrada de argumentos: Id do enviador, ID da varinha e ID do recipiente
+// A varinha muda apenas de owner, os materiais usados na sua produção continuam registrados nela
+func (c *StudioContract) TransferWand(ctx StudioContextInterface, senderID string, wandID string, receiverID string) (*Wand, error) {
+	err := validate(
+		validateIDReference("from", senderID),
+		validateIDReference("wand", wandID),
+		validateIDReference("to", receiverID),
+		validateDistinctOwners(senderID, receiverID),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verifica sender e recipiente na ledger. Só o dono do sender pode enviar
+	_, err = ctx.GetOwnerForCaller(senderID)
+	if err != nil {
+		return nil, withDetail(err, "party", "sender")
+	}
+	repo := ctx.Repository()
+	_, err = repo.findOwnerForUpdate(receiverID)
+	if err != nil {
+		return nil, withDetail(err, "party", "receiver")
+	}
+
+	wand, err := repo.findWand(wandID)
+	if err != nil {
+		return nil, err
+	}
+	if wand.Owner != senderID {
+		return nil, newError(CodeInvalidArgument, msgWandNotOwnedBySender, map[string]string{"wand": wandID, "owner": senderID})
+	}
+
+	// Remove a varinha das chaves do sender e a registra nas chaves do recipiente
+	stub := ctx.GetStub()
+	err = deleteWand(stub, wand)
+	if err != nil {
+		return nil, err
+	}
+	wand.Owner = receiverID
+	err = putWand(stub, wand)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.EmitEvent(eventWandTransferred, StudioEvent{
+		Owners: []string{senderID, receiverID},
+		From:   senderID,
+		To:     receiverID,
+		Wands:  []string{wandID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wand, nil
+}
+
+// Migra owners do formato antigo, em que materiais e varinhas ficavam dentro do documento do owner,
+// para as chaves compostas material~owner~descricao e wand~owner~wandID
+// Possui como entrada a lista de IDs dos owners a migrar. Com a lista vazia migra todos os owners da ledger. Só admins
+// IDs repetidos na lista são migrados uma vez só
+// Retorna a lista de owners migrados
+func (c *StudioContract) MigrateOwners(ctx StudioContextInterface, ownerIDs []string) ([]string, error) {
+	for _, ownerID := range ownerIDs {
+		err := validateIDReference("owners", ownerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := ctx.AssertRole(roleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := ctx.GetStub()
+	var owners []*Owner
+	if len(ownerIDs) == 0 {
+		owners, err = queryOwners(stub)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Todos os owners são lidos antes da migração, então um ID repetido migraria as mesmas varinhas duas vezes
+		seen := make(map[string]bool)
+		for _, ownerID := range ownerIDs {
+			if seen[ownerID] {
+				continue
+			}
+			seen[ownerID] = true
+			owner, err := ctx.Repository().findOwner(ownerID)
+			if err != nil {
+				return nil, err
+			}
+			owners = append(owners, owner)
+		}
+	}
+
+	migrated := []string{}
+	wandIndex := 0
+	for _, owner := range owners {
+		if !isLegacyOwner(owner) {
+			continue
+		}
+		migratedWands, err := migrateOwner(stub, owner, wandIndex)
+		if err != nil {
+			return nil, withDetail(err, "owner", owner.Id)
+		}
+		wandIndex += migratedWands
+		migrated = append(migrated, owner.Id)
+	}
+
+	err = ctx.EmitEvent(eventOwnersMigrated, StudioEvent{Owners: migrated})
+	if err != nil {
+		return nil, err
+	}
+
+	return migrated, nil
+}
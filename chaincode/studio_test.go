@@ -0,0 +1,316 @@
+package chaincode
+
+import (
+	"strings"
+	"testing"
+)
+
+// Harness configurado com os owners alice e bob, cada um vinculado à sua identidade
+func newStudioHarness(t *testing.T) (*harness, identities) {
+	t.Helper()
+	h := newConfiguredHarness(t)
+	ids := newIdentities(t)
+	h.as(ids.alice).invoke("initOwner", "alice").ok()
+	h.as(ids.bob).invoke("initOwner", "bob").ok()
+	return h, ids
+}
+
+func TestInitOwner(t *testing.T) {
+	h, ids := newStudioHarness(t)
+
+	h.as(ids.alice).invoke("initOwner", "carol").ok()
+	event := h.lastEvent(eventOwnerCreated)
+	if len(event.Owners) != 1 || event.Owners[0] != "carol" {
+		t.Fatalf("unexpected event owners %v", event.Owners)
+	}
+
+	var owner Owner
+	h.invoke("QueryOwner", "carol").decode(&owner)
+	if owner.MSPID != "Org1MSP" || owner.ClientID == "" {
+		t.Fatalf("owner not bound to the caller: %+v", owner)
+	}
+
+	h.invoke("initOwner", "carol").failsWith(CodeAlreadyExists, msgOwnerExists)
+	h.invoke("initOwner", "").failsWith(CodeInvalidArgument, msgIDEmpty)
+	h.invoke("initOwner", "not valid").failsWith(CodeInvalidArgument, msgIDInvalid)
+
+	// Uma chave com outro tipo de documento não está livre e não é sobrescrita
+	recipe := `{"docType":"recipe","id":"x","materiais":[],"ativa":true}`
+	h.putRaw("x", recipe)
+	h.invoke("initOwner", "x").failsWith(CodeAlreadyExists, msgOwnerExists)
+	h.as(ids.admin).invoke("bootstrapOwner", "x", "Org1MSP", "client-x").failsWith(CodeAlreadyExists, msgOwnerExists)
+	if string(h.stub.State["x"]) != recipe {
+		t.Fatalf("the foreign document was overwritten: %s", h.stub.State["x"])
+	}
+}
+
+func TestBootstrapOwner(t *testing.T) {
+	h, ids := newStudioHarness(t)
+
+	h.as(ids.admin).invoke("bootstrapOwner", "dave", "Org2MSP", "client-dave").ok()
+	h.lastEvent(eventOwnerCreated)
+	h.putRaw("carol", `{"docType":"owner","id":"carol"}`)
+	h.invoke("bootstrapOwner", "carol", "Org2MSP", "client-carol").ok()
+	h.lastEvent(eventOwnerBound)
+
+	var owner Owner
+	h.invoke("QueryOwner", "carol").decode(&owner)
+	if owner.MSPID != "Org2MSP" || owner.ClientID != "client-carol" {
+		t.Fatalf("owner not bound: %+v", owner)
+	}
+
+	// Um owner já vinculado não pode ser tomado por outra identidade
+	var alice Owner
+	h.invoke("QueryOwner", "alice").decode(&alice)
+	h.invoke("bootstrapOwner", "alice", "Org2MSP", "client-alice").failsWith(CodeAlreadyExists, msgOwnerBound)
+	h.invoke("bootstrapOwner", "carol", "Org2MSP", "client-dave").failsWith(CodeAlreadyExists, msgOwnerBound)
+	h.invoke("QueryOwner", "alice").decode(&owner)
+	if owner.MSPID != alice.MSPID || owner.ClientID != alice.ClientID {
+		t.Fatalf("bound owner was taken over: %+v", owner)
+	}
+
+	h.as(ids.alice).invoke("bootstrapOwner", "eve", "Org1MSP", "client-eve").failsWith(CodeUnauthorized, msgAccessMissingRole)
+	h.as(ids.admin).invoke("bootstrapOwner", "eve", "Org1MSP", "").failsWith(CodeInvalidArgument, msgIDEmpty)
+}
+
+func TestQueryOwner(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "3", "alice").ok()
+
+	var owner Owner
+	h.invoke("QueryOwner", "alice").decode(&owner)
+	if owner.Id != "alice" || descriptions(owner.Materiais) != "ebano=3" || len(owner.Wands) != 0 {
+		t.Fatalf("unexpected owner %+v", owner)
+	}
+
+	h.invoke("QueryOwner", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+}
+
+func TestInitMaterial(t *testing.T) {
+	h, ids := newStudioHarness(t)
+
+	h.as(ids.alice).invoke("initMaterial", "ebano", "3", "alice").ok()
+	event := h.lastEvent(eventMaterialMinted)
+	if descriptions(event.Materiais) != "ebano=3" {
+		t.Fatalf("unexpected event materials %v", event.Materiais)
+	}
+	// Cunhar de novo soma na mesma pilha
+	h.invoke("initMaterial", "ebano", "2", "alice").ok()
+	if quantity := h.quantity("alice", "ebano"); quantity != 5 {
+		t.Fatalf("expected 5 ebano, got %d", quantity)
+	}
+
+	h.invoke("initMaterial", "ebano", "1", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	h.invoke("initMaterial", "ebano", "0", "alice").failsWith(CodeInvalidArgument, msgQuantityNotPositive)
+	h.invoke("initMaterial", "ebano", "-1", "alice").failsWith(CodeInvalidArgument, msgQuantityNotPositive)
+	h.invoke("initMaterial", "", "1", "alice").failsWith(CodeInvalidArgument, msgDescriptionEmpty)
+	h.invoke("initMaterial", "ebano", "x", "alice").failsWith(CodeInvalidArgument, msgContractArguments)
+	// bob é supplier, mas não é o dono de alice
+	h.as(ids.bob).invoke("initMaterial", "ebano", "1", "alice").failsWith(CodeUnauthorized, msgAccessNotOwner)
+	h.as(ids.admin).invoke("initMaterial", "ebano", "1", "alice").failsWith(CodeUnauthorized, msgAccessMissingRole)
+}
+
+func TestGetMaterialsAndWands(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "3", "alice").ok()
+	h.invoke("initMaterial", "rubi", "3", "alice").ok()
+	h.as(ids.bob).invoke("initMaterial", "pena", "1", "bob").ok()
+	h.as(ids.alice).invoke("createWand", "alice").ok()
+
+	var materials []Material
+	h.invoke("getMaterials").decode(&materials)
+	if descriptions(materials) != "ebano=2,pena=1,rubi=2" {
+		t.Fatalf("unexpected materials %v", materials)
+	}
+	var wands []Wand
+	h.invoke("getWands").decode(&wands)
+	if len(wands) != 1 || wands[0].Owner != "alice" {
+		t.Fatalf("unexpected wands %v", wands)
+	}
+
+	// Páginas de 2 materiais
+	var page MaterialsPage
+	h.invoke("getMaterials", "2").decode(&page)
+	if page.FetchedRecordsCount != 2 || page.Bookmark == "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	var next MaterialsPage
+	h.invoke("getMaterials", "2", page.Bookmark).decode(&next)
+	if next.FetchedRecordsCount != 1 || next.Bookmark != "" {
+		t.Fatalf("unexpected second page %+v", next)
+	}
+	if descriptions(append(page.Records, next.Records...)) != "ebano=2,pena=1,rubi=2" {
+		t.Fatalf("pages do not add up to all materials: %v %v", page.Records, next.Records)
+	}
+	var wandsPage WandsPage
+	h.invoke("getWands", "10").decode(&wandsPage)
+	if wandsPage.FetchedRecordsCount != 1 {
+		t.Fatalf("unexpected wands page %+v", wandsPage)
+	}
+
+	h.invoke("getMaterials", "0").failsWith(CodeInvalidArgument, msgPageSize)
+	h.invoke("getWands", "-1").failsWith(CodeInvalidArgument, msgPageSize)
+	h.invoke("getWands", "x").failsWith(CodeInvalidArgument, msgContractArguments)
+}
+
+func TestSwapMaterials(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "5", "alice").ok()
+
+	h.invoke("swapMaterials", "alice", "ebano", "2", "bob").ok()
+	event := h.lastEvent(eventMaterialTransferred)
+	if event.From != "alice" || event.To != "bob" || descriptions(event.Materiais) != "ebano=2" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if h.quantity("alice", "ebano") != 3 || h.quantity("bob", "ebano") != 2 {
+		t.Fatalf("expected 3 and 2 ebano, got %d and %d", h.quantity("alice", "ebano"), h.quantity("bob", "ebano"))
+	}
+	// Transferir tudo apaga a entrada do sender
+	h.invoke("swapMaterials", "alice", "ebano", "3", "bob").ok()
+	if _, ok := h.stub.State[h.compositeKey(materialIndex, "alice", "ebano")]; ok {
+		t.Fatalf("empty stack was not deleted")
+	}
+
+	h.as(ids.bob).invoke("swapMaterials", "bob", "ebano", "6", "alice").failsWith(CodeInsufficientQuantity, msgInsufficientQuantity)
+	h.invoke("swapMaterials", "bob", "rubi", "1", "alice").failsWith(CodeMaterialNotFound, msgMaterialNotFound)
+	h.invoke("swapMaterials", "bob", "ebano", "1", "bob").failsWith(CodeInvalidArgument, msgSelfTransfer)
+	h.invoke("swapMaterials", "bob", "ebano", "-1", "alice").failsWith(CodeInvalidArgument, msgQuantityNotPositive)
+	studioErr := h.invoke("swapMaterials", "bob", "ebano", "1", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	if studioErr.Details["party"] != "receiver" {
+		t.Fatalf("expected the receiver to be reported, got %v", studioErr.Details)
+	}
+	h.invoke("swapMaterials", "alice", "ebano", "1", "bob").failsWith(CodeUnauthorized, msgAccessNotOwner)
+	h.invoke("swapMaterials", "bob", "ebano", "1").failsWith(CodeInvalidArgument, msgArgumentCount)
+}
+
+// Owners e pilhas criados antes das regras de formato continuam acessíveis: as buscas só recusam o que quebra as chaves
+func TestItemsCreatedBeforeValidation(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	var alice Owner
+	h.as(ids.alice).invoke("QueryOwner", "alice").decode(&alice)
+	h.putRaw("dona maria", `{"docType":"owner","id":"dona maria","materiais":[],"wands":[],"mspId":"`+alice.MSPID+`","clientId":"`+alice.ClientID+`"}`)
+	long := strings.Repeat("é", maxDescriptionLength+1)
+	h.putRaw(h.compositeKey(materialIndex, "dona maria", long), `{"docType":"material","descricao":"`+long+`","quantidade":2,"owner":"dona maria"}`)
+
+	h.invoke("QueryOwner", "dona maria").ok()
+	h.invoke("initMaterial", "ebano", "1", "dona maria").ok()
+	h.invoke("swapMaterials", "dona maria", long, "1", "bob").ok()
+	if h.quantity("dona maria", long) != 1 || h.quantity("bob", long) != 1 {
+		t.Fatalf("the long stack was not moved")
+	}
+	h.invoke("getHistory", "dona maria").ok()
+	h.invoke("getHistory", "dona maria", long).ok()
+	h.as(ids.admin).invoke("migrateOwners", "dona maria").ok()
+	h.invoke("bootstrapOwner", "dona maria", alice.MSPID, alice.ClientID).failsWith(CodeAlreadyExists, msgOwnerBound)
+
+	// Criar continua exigindo o formato
+	h.invoke("bootstrapOwner", "dona joana", alice.MSPID, alice.ClientID).failsWith(CodeInvalidArgument, msgIDInvalid)
+	h.as(ids.alice).invoke("initOwner", "dona joana").failsWith(CodeInvalidArgument, msgIDInvalid)
+	h.invoke("initMaterial", long, "1", "alice").failsWith(CodeInvalidArgument, msgDescriptionTooLong)
+	h.invoke("QueryOwner", "dona\x00maria").failsWith(CodeInvalidArgument, msgKeyInvalid)
+}
+
+func TestCreateWand(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "2", "alice").ok()
+
+	h.invoke("createWand", "alice").failsWith(CodeInsufficientQuantity, msgWandNotEnoughMaterials)
+
+	h.invoke("initMaterial", "rubi", "1", "alice").ok()
+	var wand Wand
+	h.invoke("createWand", "alice").decode(&wand)
+	if wand.Owner != "alice" || wand.Id == "" || descriptions(wand.Materiais) != "ebano=1,rubi=1" {
+		t.Fatalf("unexpected wand %+v", wand)
+	}
+	event := h.lastEvent(eventWandCreated)
+	if len(event.Wands) != 1 || event.Wands[0] != wand.Id {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if h.quantity("alice", "ebano") != 1 || h.quantity("alice", "rubi") != 0 {
+		t.Fatalf("materials were not consumed")
+	}
+
+	h.invoke("createWand", "alice").failsWith(CodeInsufficientQuantity, msgWandNotEnoughMaterials)
+	h.invoke("createWand", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	// bob não é wandmaker
+	h.as(ids.bob).invoke("createWand", "bob").failsWith(CodeUnauthorized, msgAccessMissingRole)
+}
+
+func TestGetWand(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "alice").ok()
+	h.invoke("initMaterial", "rubi", "1", "alice").ok()
+	var created Wand
+	h.invoke("createWand", "alice").decode(&created)
+
+	var wand Wand
+	h.invoke("getWand", created.Id).decode(&wand)
+	if wand.Id != created.Id || wand.Owner != "alice" {
+		t.Fatalf("unexpected wand %+v", wand)
+	}
+
+	h.invoke("getWand", "nope").failsWith(CodeWandNotFound, msgWandNotFound)
+	h.invoke("getWand", "").failsWith(CodeInvalidArgument, msgIDEmpty)
+}
+
+func TestTransferWand(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "alice").ok()
+	h.invoke("initMaterial", "rubi", "1", "alice").ok()
+	var created Wand
+	h.invoke("createWand", "alice").decode(&created)
+
+	var wand Wand
+	h.invoke("transferWand", "alice", created.Id, "bob").decode(&wand)
+	if wand.Owner != "bob" {
+		t.Fatalf("wand not transferred: %+v", wand)
+	}
+	event := h.lastEvent(eventWandTransferred)
+	if event.From != "alice" || event.To != "bob" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	var owner Owner
+	h.invoke("QueryOwner", "bob").decode(&owner)
+	if len(owner.Wands) != 1 || owner.Wands[0].Id != created.Id {
+		t.Fatalf("bob does not hold the wand: %+v", owner)
+	}
+
+	h.invoke("transferWand", "alice", created.Id, "bob").failsWith(CodeInvalidArgument, msgWandNotOwnedBySender)
+	h.invoke("transferWand", "alice", "nope", "bob").failsWith(CodeWandNotFound, msgWandNotFound)
+	h.invoke("transferWand", "alice", created.Id, "alice").failsWith(CodeInvalidArgument, msgSelfTransfer)
+	h.invoke("transferWand", "alice", created.Id, "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+	h.as(ids.alice).invoke("transferWand", "bob", created.Id, "alice").failsWith(CodeUnauthorized, msgAccessNotOwner)
+}
+
+func TestMigrateOwners(t *testing.T) {
+	h, ids := newStudioHarness(t)
+	h.putRaw("old", `{"docType":"owner","id":"old","materiais":[{"descricao":"ebano","quantidade":2},{"descricao":"ebano","quantidade":3}],"wands":[{"materiais":[],"quantidade":1}]}`)
+
+	h.as(ids.alice).invoke("initMaterial", "ebano", "1", "old").failsWith(CodeMigrationRequired, msgOwnerLegacy)
+
+	// Só admins migram
+	h.invoke("migrateOwners").failsWith(CodeUnauthorized, msgAccessMissingRole)
+
+	// Um ID repetido é migrado uma vez só, sem duplicar as varinhas
+	var migrated []string
+	h.as(ids.admin).invoke("migrateOwners", "old", "old").decode(&migrated)
+	if len(migrated) != 1 || migrated[0] != "old" {
+		t.Fatalf("unexpected migrated owners %v", migrated)
+	}
+	h.lastEvent(eventOwnersMigrated)
+	if h.quantity("old", "ebano") != 5 {
+		t.Fatalf("expected the legacy stacks to be merged into 5 ebano, got %d", h.quantity("old", "ebano"))
+	}
+	var owner Owner
+	h.invoke("QueryOwner", "old").decode(&owner)
+	if len(owner.Wands) != 1 || owner.Wands[0].Id == "" {
+		t.Fatalf("legacy wand was not migrated: %+v", owner)
+	}
+
+	// Owners já migrados não são migrados de novo
+	h.invoke("migrateOwners", "old", "alice").decode(&migrated)
+	if len(migrated) != 0 {
+		t.Fatalf("unexpected migrated owners %v", migrated)
+	}
+	h.invoke("migrateOwners", "nobody").failsWith(CodeOwnerNotFound, msgOwnerNotFound)
+}
@@ -0,0 +1,171 @@
+package chaincode
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Regras das entradas das transações. Toda transação valida seus argumentos aqui antes de ler a ledger,
+// e cada regra devolve a sua própria mensagem. Os nomes dos campos nos detalhes são os da forma JSON (ver requestSpecs)
+const (
+	// IDs de varinha são o TxID (64 caracteres) seguido de "-" e do índice
+	maxIDLength          = 128
+	maxDescriptionLength = 64
+	// Cada varinha grava duas chaves (a varinha e o índice); o limite mantém o createWand em uma transação de tamanho razoável
+	maxWandCount = 100
+)
+
+// IDs começam por letra ou número e usam só letras, números, ".", "_" e "-"
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Devolve o primeiro erro das validações, na ordem dos argumentos da transação
+func validate(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ID de um owner, varinha ou receita criado pela transação (initOwner, bootstrapOwner, registerRecipe).
+// Os IDs que só buscam um documento passam por validateIDReference: owners criados antes destas regras
+// podem ter qualquer ID e continuam acessíveis
+func validateID(field string, id string) error {
+	if id == "" {
+		return newError(CodeInvalidArgument, msgIDEmpty, map[string]string{"field": field})
+	}
+	if len(id) > maxIDLength {
+		return newError(CodeInvalidArgument, msgIDTooLong, map[string]string{"field": field, "max": strconv.Itoa(maxIDLength)})
+	}
+	if !idPattern.MatchString(id) {
+		return newError(CodeInvalidArgument, msgIDInvalid, map[string]string{"field": field, "value": id})
+	}
+	return nil
+}
+
+// ID que busca um documento existente. Só recusa o vazio e o que quebra as chaves compostas
+func validateIDReference(field string, id string) error {
+	if id == "" {
+		return newError(CodeInvalidArgument, msgIDEmpty, map[string]string{"field": field})
+	}
+	return validateKeyPart(field, id)
+}
+
+// O stub recusa nas chaves compostas UTF-8 inválido, o separador U+0000 e U+10FFFF, que fecha as buscas por prefixo
+func validateKeyPart(field string, value string) error {
+	if !utf8.ValidString(value) || strings.ContainsRune(value, 0) || strings.ContainsRune(value, utf8.MaxRune) {
+		return newError(CodeInvalidArgument, msgKeyInvalid, map[string]string{"field": field, "value": value})
+	}
+	return nil
+}
+
+// Valor que não segue o formato dos IDs, mas não pode ser vazio (o ID do cliente do cid é base64)
+func validateNotEmpty(field string, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return newError(CodeInvalidArgument, msgIDEmpty, map[string]string{"field": field})
+	}
+	return nil
+}
+
+// Descrição de um material cunhado pela transação (initMaterial): texto curto, sem caracteres de controle
+// (que corrompem as chaves compostas) nem espaços nas pontas. As descrições que só buscam uma pilha passam por
+// validateDescriptionReference, para que pilhas antigas fora destas regras continuem movimentáveis
+func validateDescription(descricao string) error {
+	if strings.TrimSpace(descricao) == "" {
+		return newError(CodeInvalidArgument, msgDescriptionEmpty, nil)
+	}
+	if !utf8.ValidString(descricao) || strings.TrimSpace(descricao) != descricao || strings.IndexFunc(descricao, unicode.IsControl) >= 0 {
+		return newError(CodeInvalidArgument, msgDescriptionInvalid, map[string]string{"material": descricao})
+	}
+	if utf8.RuneCountInString(descricao) > maxDescriptionLength {
+		return newError(CodeInvalidArgument, msgDescriptionTooLong, map[string]string{"material": descricao, "max": strconv.Itoa(maxDescriptionLength)})
+	}
+	return nil
+}
+
+// Descrição que busca uma pilha existente ou que uma receita consome
+func validateDescriptionReference(descricao string) error {
+	if strings.TrimSpace(descricao) == "" {
+		return newError(CodeInvalidArgument, msgDescriptionEmpty, nil)
+	}
+	return validateKeyPart("material", descricao)
+}
+
+// Forma guardada na ledger de uma descrição já validada: Unicode NFC e espaços internos simples.
+// Material não tem outros atributos, então descrições com a mesma forma canônica são a mesma pilha
+// ("ébano" digitado em NFD e em NFC, "pena  de fênix" e "pena de fênix")
+func canonicalDescription(descricao string) string {
+	return norm.NFC.String(strings.Join(strings.Fields(descricao), " "))
+}
+
+// Quantidade movimentada, cunhada ou produzida
+func validateQuantity(field string, quantity int) error {
+	if quantity <= 0 {
+		return newError(CodeInvalidArgument, msgQuantityNotPositive, map[string]string{"field": field, "value": strconv.Itoa(quantity)})
+	}
+	return nil
+}
+
+// Número de varinhas produzidas de uma vez pelo createWand com receita
+func validateWandCount(count int) error {
+	err := validateQuantity("count", count)
+	if err != nil {
+		return err
+	}
+	if count > maxWandCount {
+		return newError(CodeInvalidArgument, msgWandCountTooLarge, map[string]string{"max": strconv.Itoa(maxWandCount), "value": strconv.Itoa(count)})
+	}
+	return nil
+}
+
+// Limite inferior de uma consulta, que pode ser zero
+func validateMinQuantity(field string, quantity int) error {
+	if quantity < 0 {
+		return newError(CodeInvalidArgument, msgQuantityNegative, map[string]string{"field": field, "value": strconv.Itoa(quantity)})
+	}
+	return nil
+}
+
+// Tamanho de página pedido no getMaterials e getWands paginados
+func validatePageSize(pageSize int32) error {
+	if pageSize <= 0 {
+		return newError(CodeInvalidArgument, msgPageSize, nil)
+	}
+	return nil
+}
+
+// Transferências entre o owner e ele mesmo não mudam nada e não são aceitas
+func validateDistinctOwners(senderID string, receiverID string) error {
+	if senderID == receiverID {
+		return newError(CodeInvalidArgument, msgSelfTransfer, map[string]string{"owner": senderID})
+	}
+	return nil
+}
+
+// Soma de quantidades não negativas. ok é false se o resultado não cabe em um int
+func addQuantities(a int, b int) (int, bool) {
+	if a > math.MaxInt-b {
+		return 0, false
+	}
+	return a + b, true
+}
+
+// Produto de quantidades não negativas. ok é false se o resultado não cabe em um int
+func multiplyQuantities(a int, b int) (int, bool) {
+	if a != 0 && b > math.MaxInt/a {
+		return 0, false
+	}
+	return a * b, true
+}
+
+// Erro de uma quantidade que estouraria o int
+func quantityOverflowError(ownerID string, descricao string) error {
+	return newError(CodeInvalidArgument, msgQuantityOverflow, map[string]string{"owner": ownerID, "material": descricao})
+}
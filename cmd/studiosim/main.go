@@ -0,0 +1,67 @@
+// Simulador offline do chaincode Studio: hospeda o StudioChaincode em uma ledger em memória e executa
+// comandos de um script ou de um REPL, mostrando a resposta, os eventos e as mudanças da ledger de cada transação.
+//
+//	go run ./cmd/studiosim                        # REPL
+//	go run ./cmd/studiosim cmd/studiosim/example.sim
+//
+// Digite help para ver os comandos
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"main/chaincode"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [script]\n\nRuns the Studio chaincode on an in-memory ledger.\n"+
+			"Commands are read from the script file, or interactively from stdin. Type help for the commands.\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	studio, err := chaincode.NewStudioChaincode()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating Studio chaincode: %s\n", err)
+		os.Exit(1)
+	}
+	sim, err := newSimulator(studio, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating simulator: %s\n", err)
+		os.Exit(1)
+	}
+
+	var input io.Reader = os.Stdin
+	interactive := isTerminal(os.Stdin)
+	if flag.NArg() == 1 && flag.Arg(0) != "-" {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening script: %s\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		input = file
+		interactive = false
+	}
+
+	err = sim.run(bufio.NewScanner(input), interactive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+// Com o stdin em um terminal o simulador mostra o prompt e não para nos erros de comando
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
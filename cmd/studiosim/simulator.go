@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+
+	"main/chaincode/memstub"
+)
+
+const helpText = `Commands:
+  init [config]                           Init, optionally with the JSON configuration and initial owners and recipes
+  invoke <function> [args...]             submit a transaction, e.g. invoke initMaterial ebano 3 alice
+  identity <name> <mspId> [attr=value...] create a client identity and switch to it
+  as <name>                               switch to an identity created before
+  language [pt-BR|en]                     language of the messages (transient field); no argument clears it
+  state [prefix]                          print the world state, optionally only the keys starting with prefix
+  help                                    this text
+  exit                                    leave the simulator
+Arguments with spaces or JSON go in quotes: invoke registerRecipe classic '[{"descricao":"ebano","quantidade":1}]'
+Composite keys are printed with ~ between the parts (material~alice~ebano); lines starting with # are comments.
+Failed transactions are discarded, as on a peer: their writes and events do not reach the ledger.
+`
+
+// Identidade que submete as transações até o primeiro identity/as
+const defaultIdentity = "user"
+
+// Ledger em memória com o chaincode e as identidades dos clientes simulados
+type simulator struct {
+	cc         shim.Chaincode
+	stub       *memstub.Stub
+	out        io.Writer
+	identities map[string][]byte
+	identity   string
+	txs        int
+}
+
+func newSimulator(cc shim.Chaincode, out io.Writer) (*simulator, error) {
+	sim := &simulator{
+		cc:         cc,
+		stub:       memstub.New("studio", cc),
+		out:        out,
+		identities: make(map[string][]byte),
+	}
+	err := sim.addIdentity(defaultIdentity, "Org1MSP", nil)
+	if err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
+// Executa os comandos até o fim da entrada ou um exit.
+// Fora do modo interativo o primeiro erro de comando interrompe o script; falhas das transações não são erros de comando
+func (sim *simulator) run(scanner *bufio.Scanner, interactive bool) error {
+	line := 0
+	for {
+		if interactive {
+			fmt.Fprintf(sim.out, "studio(%s)> ", sim.identity)
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line++
+		words, err := splitWords(scanner.Text())
+		if err == nil && len(words) > 0 && (words[0] == "exit" || words[0] == "quit") {
+			return nil
+		}
+		if err == nil {
+			err = sim.execute(words)
+		}
+		if err != nil {
+			if !interactive {
+				return fmt.Errorf("line %d: %s", line, err)
+			}
+			fmt.Fprintf(sim.out, "error: %s\n", err)
+		}
+	}
+	if interactive {
+		fmt.Fprintln(sim.out)
+	}
+	return scanner.Err()
+}
+
+func (sim *simulator) execute(words []string) error {
+	if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+		return nil
+	}
+	command, args := words[0], words[1:]
+	switch command {
+	case "init":
+		sim.transaction(append([]string{"init"}, args...), func() pb.Response { return sim.cc.Init(sim.stub) })
+	case "invoke":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: invoke <function> [args...]")
+		}
+		sim.transaction(args, func() pb.Response { return sim.cc.Invoke(sim.stub) })
+	case "identity":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: identity <name> <mspId> [attr=value...]")
+		}
+		attributes := make(map[string]string)
+		for _, attribute := range args[2:] {
+			name, value, ok := strings.Cut(attribute, "=")
+			if !ok {
+				return fmt.Errorf("attribute %q is not attr=value", attribute)
+			}
+			attributes[name] = value
+		}
+		return sim.addIdentity(args[0], args[1], attributes)
+	case "as":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: as <name>")
+		}
+		if _, ok := sim.identities[args[0]]; !ok {
+			return fmt.Errorf("unknown identity %q, create it with identity <name> <mspId>", args[0])
+		}
+		sim.identity = args[0]
+	case "language":
+		if len(args) > 1 {
+			return fmt.Errorf("usage: language [pt-BR|en]")
+		}
+		sim.stub.TransientMap = nil
+		if len(args) == 1 {
+			sim.stub.TransientMap = map[string][]byte{"language": []byte(args[0])}
+		}
+	case "state":
+		if len(args) > 1 {
+			return fmt.Errorf("usage: state [prefix]")
+		}
+		prefix := ""
+		if len(args) == 1 {
+			prefix = args[0]
+		}
+		sim.printState(prefix)
+	case "help":
+		fmt.Fprint(sim.out, helpText)
+	default:
+		return fmt.Errorf("unknown command %q, type help for the commands", command)
+	}
+	return nil
+}
+
+func (sim *simulator) addIdentity(name string, mspID string, attributes map[string]string) error {
+	identity, err := memstub.NewIdentity(mspID, name, attributes)
+	if err != nil {
+		return fmt.Errorf("creating identity %s: %s", name, err)
+	}
+	sim.identities[name] = identity
+	sim.identity = name
+	return nil
+}
+
+// Submete uma transação com a identidade atual e mostra a resposta, os eventos e as chaves que ela gravou ou apagou
+func (sim *simulator) transaction(args []string, run func() pb.Response) {
+	sim.txs++
+	txID := fmt.Sprintf("tx%d", sim.txs)
+	sim.stub.Creator = sim.identities[sim.identity]
+
+	before := make(map[string][]byte, len(sim.stub.State))
+	for key, value := range sim.stub.State {
+		before[key] = value
+	}
+	restore := sim.stub.Checkpoint()
+	response := sim.stub.Call(txID, args, run)
+	events := sim.stub.Events()
+
+	fmt.Fprintf(sim.out, "%s %s status %d\n", txID, sim.identity, response.Status)
+	if response.Status >= shim.ERRORTHRESHOLD {
+		restore()
+		fmt.Fprintf(sim.out, "error:\n%s\n", indentJSON([]byte(response.Message)))
+		return
+	}
+	if len(response.Payload) > 0 {
+		fmt.Fprintf(sim.out, "payload:\n%s\n", indentJSON(response.Payload))
+	}
+	for _, event := range events {
+		fmt.Fprintf(sim.out, "event %s:\n%s\n", event.EventName, indentJSON(event.Payload))
+	}
+
+	var changes []string
+	for key, value := range sim.stub.State {
+		previous, ok := before[key]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("  + %s = %s", sim.displayKey(key), value))
+		} else if !bytes.Equal(previous, value) {
+			changes = append(changes, fmt.Sprintf("  ~ %s = %s", sim.displayKey(key), value))
+		}
+	}
+	for key := range before {
+		if _, ok := sim.stub.State[key]; !ok {
+			changes = append(changes, fmt.Sprintf("  - %s", sim.displayKey(key)))
+		}
+	}
+	if len(changes) > 0 {
+		// Ordena pela chave, depois do marcador da mudança
+		sort.Slice(changes, func(i, j int) bool { return changes[i][4:] < changes[j][4:] })
+		fmt.Fprintf(sim.out, "state:\n%s\n", strings.Join(changes, "\n"))
+	}
+}
+
+func (sim *simulator) printState(prefix string) {
+	count := 0
+	for element := sim.stub.Keys.Front(); element != nil; element = element.Next() {
+		key := element.Value.(string)
+		display := sim.displayKey(key)
+		if !strings.HasPrefix(display, prefix) {
+			continue
+		}
+		fmt.Fprintf(sim.out, "%s = %s\n", display, sim.stub.State[key])
+		count++
+	}
+	fmt.Fprintf(sim.out, "(%d keys)\n", count)
+}
+
+// Chaves compostas como objectType~atributo~atributo, como nos comentários do chaincode
+func (sim *simulator) displayKey(key string) string {
+	if !strings.HasPrefix(key, "\x00") {
+		return key
+	}
+	objectType, attributes, err := sim.stub.SplitCompositeKey(key)
+	if err != nil {
+		return fmt.Sprintf("%q", key)
+	}
+	return strings.Join(append([]string{objectType}, attributes...), "~")
+}
+
+func indentJSON(value []byte) string {
+	var indented bytes.Buffer
+	err := json.Indent(&indented, value, "  ", "  ")
+	if err != nil {
+		return "  " + string(value)
+	}
+	return "  " + indented.String()
+}
+
+// Separa uma linha em palavras. Aspas simples guardam o texto como está (bom para JSON);
+// nas aspas duplas \" e \\ são escapes
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"main/chaincode"
+)
+
+func TestSplitWords(t *testing.T) {
+	words, err := splitWords(`invoke registerRecipe 'a b' '[{"descricao":"pena de fênix"}]' "x \"y\" \\" ''`)
+	if err != nil {
+		t.Fatalf("splitWords: %s", err)
+	}
+	expected := []string{"invoke", "registerRecipe", "a b", `[{"descricao":"pena de fênix"}]`, `x "y" \`, ""}
+	if !reflect.DeepEqual(words, expected) {
+		t.Fatalf("expected %q, got %q", expected, words)
+	}
+	_, err = splitWords(`invoke initOwner 'alice`)
+	if err == nil {
+		t.Fatalf("expected an error for the unterminated quote")
+	}
+}
+
+func newTestSimulator(t *testing.T) (*simulator, *bytes.Buffer) {
+	t.Helper()
+	studio, err := chaincode.NewStudioChaincode()
+	if err != nil {
+		t.Fatalf("NewStudioChaincode: %s", err)
+	}
+	var out bytes.Buffer
+	sim, err := newSimulator(studio, &out)
+	if err != nil {
+		t.Fatalf("newSimulator: %s", err)
+	}
+	return sim, &out
+}
+
+func TestExampleScript(t *testing.T) {
+	sim, out := newTestSimulator(t)
+	script, err := os.Open("example.sim")
+	if err != nil {
+		t.Fatalf("Open: %s", err)
+	}
+	defer script.Close()
+
+	err = sim.run(bufio.NewScanner(script), false)
+	if err != nil {
+		t.Fatalf("run: %s\n%s", err, out)
+	}
+	for _, expected := range []string{
+		"tx10 alice status 409",
+		"+ material~bob~ebano = ",
+		`"message": "Owner does not exist: carol"`,
+		"(2 keys)",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("output does not contain %q:\n%s", expected, out)
+		}
+	}
+}
+
+// As escritas e os eventos de uma transação que falha são descartados
+func TestFailedTransactionIsDiscarded(t *testing.T) {
+	sim, out := newTestSimulator(t)
+	script := strings.Join([]string{
+		"invoke initOwner alice",
+		"invoke initMaterial ebano 1 alice",
+		"invoke swapMaterials alice ebano 5 nobody",
+	}, "\n")
+	err := sim.run(bufio.NewScanner(strings.NewReader(script)), false)
+	if err != nil {
+		t.Fatalf("run: %s", err)
+	}
+	if !strings.Contains(out.String(), "tx3 user status 404") {
+		t.Fatalf("expected the swap to fail:\n%s", out)
+	}
+	if len(sim.stub.State) != 2 {
+		t.Fatalf("expected only the owner and the material in the ledger, got %d keys", len(sim.stub.State))
+	}
+
+	err = sim.run(bufio.NewScanner(strings.NewReader("as nobody")), false)
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected an error on line 1, got %v", err)
+	}
+}